@@ -2,83 +2,310 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"shortURL/internal/analytics"
+	"shortURL/internal/auth"
 	"shortURL/internal/config"
 	"shortURL/internal/handler"
+	"shortURL/internal/logging"
+	"shortURL/internal/loopguard"
+	"shortURL/internal/metrics"
+	"shortURL/internal/policy"
+	"shortURL/internal/ratelimit"
 	"shortURL/internal/repository"
+	"shortURL/internal/repository/cache"
+	"shortURL/internal/repository/file"
 	"shortURL/internal/repository/memory"
 	"shortURL/internal/repository/postgres"
 	"shortURL/internal/service"
+	"shortURL/pkg/shortener"
 )
 
 func main() {
 	// загрузка конфигураций
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("failed to load config", "error", err)
 	}
 
-	log.Printf("Storage type: %s", cfg.StorageType)
-	log.Printf("Base URL: %s", cfg.BaseURL)
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fatal("failed to set up logging", "error", err)
+	}
+	// стандартный log тоже уходит в slog, например ошибки http.Server
+	slog.SetDefault(logger)
+
+	// подкоманда migrate только применяет миграции и завершается
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			fatal("migration failed", "error", err)
+		}
+		return
+	}
+
+	slog.Info("starting", "storage", cfg.StorageType, "base_url", cfg.BaseURL)
+
+	if err := shortener.SetAliasPolicy(cfg.AliasPolicy()); err != nil {
+		fatal("failed to set alias policy", "error", err)
+	}
+	if err := shortener.SetCodeFormat(cfg.CodeFormat(), cfg.PreviousCodeFormats...); err != nil {
+		fatal("failed to set code format", "error", err)
+	}
 
 	// инициализируем в зависимости от типа хранения
 	var repo repository.URLRepository
+	var clicks repository.ClickRepository
+	var keys repository.KeyRepository
+	var sequence repository.SequenceRepository
 	var cleanup func()
 
 	switch cfg.StorageType {
 	case "memory":
-		log.Println("Using in-memory storage")
-		repo = memory.NewMemoryRepository()
+		memRepo := memory.NewMemoryRepository()
+		if cfg.MemorySnapshotPath != "" {
+			slog.Info("using in-memory storage", "snapshot_path", cfg.MemorySnapshotPath)
+			memRepo, err = memory.NewPersistentMemoryRepository(memory.PersistConfig{
+				SnapshotPath:     cfg.MemorySnapshotPath,
+				SnapshotInterval: cfg.MemorySnapshotInterval,
+				Fsync:            memory.FsyncPolicy(cfg.MemoryFsync),
+			})
+			if err != nil {
+				fatal("failed to restore in-memory storage", "error", err)
+			}
+		} else {
+			slog.Info("using in-memory storage")
+		}
+		repo = memRepo
+		clicks = memRepo
+		keys = memRepo
+		sequence = memRepo
 		cleanup = func() {
+			// для сохраняемого хранилища пишет финальный снапшот
+			if err := repo.Close(); err != nil {
+				slog.Error("failed to close in-memory storage", "error", err)
+			}
+		}
+
+	case "file":
+		slog.Info("using file storage", "path", cfg.FilePath)
+		fileRepo, err := file.NewFileRepository(cfg.FilePath)
+		if err != nil {
+			fatal("failed to open file storage", "error", err)
+		}
+		repo = fileRepo
+		clicks = fileRepo
+		keys = fileRepo
+		sequence = fileRepo
+		cleanup = func() {
+			slog.Info("closing file storage")
 			repo.Close()
 		}
 
 	case "postgres":
-		log.Println("Connecting to PostgreSQL")
+		slog.Info("connecting to PostgreSQL", "host", cfg.PostgresHost, "db", cfg.PostgresDB)
 		pgRepo, err := postgres.NewPostgresRepository(cfg.PostgresConnectionString())
 		if err != nil {
-			log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+			fatal("failed to connect to PostgreSQL", "error", err)
 		}
 
-		// инициализируем схему
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		if err := pgRepo.InitSchema(ctx); err != nil {
+		// накатываем миграции
+		if cfg.MigrateOnStart {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if err := pgRepo.Migrate(ctx); err != nil {
+				cancel()
+				fatal("failed to migrate schema", "error", err)
+			}
 			cancel()
-			log.Fatalf("Failed to initialize schema: %v", err)
 		}
-		cancel()
 
-		log.Println("PostgreSQL connected and schema initialized")
+		slog.Info("PostgreSQL connected", "migrated", cfg.MigrateOnStart)
 		repo = pgRepo
+		clicks = pgRepo
+		keys = pgRepo
+		sequence = pgRepo
 		cleanup = func() {
-			log.Println("Closing PostgreSQL connection")
+			slog.Info("closing PostgreSQL connection")
 			repo.Close()
 		}
 
 	default:
-		log.Fatalf("Unknown storage type: %s", cfg.StorageType)
+		fatal("unknown storage type", "storage", cfg.StorageType)
+	}
+
+	atExit(cleanup)
+
+	// метрики хранилища снимаются под кэшем, чтобы видеть реальные обращения
+	var registry *metrics.Registry
+	if cfg.MetricsEnabled {
+		registry = metrics.NewRegistry()
+		registry.Register(metrics.NewRuntimeCollector())
+
+		repoMetrics := metrics.NewRepositoryMetrics(registry)
+		repo = metrics.NewInstrumentedRepository(repo, cfg.StorageType, repoMetrics)
+		clicks = metrics.NewInstrumentedClickRepository(clicks, cfg.StorageType, repoMetrics)
+	}
+
+	// кэш стоит только перед ссылками, переходы пишутся напрямую
+	if cfg.CacheEnabled {
+		cachedRepo := cache.NewCachedRepository(repo, cache.Config{
+			Size:        cfg.CacheSize,
+			TTL:         cfg.CacheTTL,
+			NegativeTTL: cfg.CacheNegativeTTL,
+		})
+		if registry != nil {
+			registry.Register(metrics.NewCacheCollector(cachedRepo))
+		}
+		repo = cachedRepo
+		slog.Info("link cache enabled", "size", cfg.CacheSize, "ttl", cfg.CacheTTL)
+	}
+
+	// фоновое удаление истекших ссылок
+	sweeper := repository.NewSweeper(repo, cfg.SweepInterval)
+	sweeper.Start()
+	atExit(sweeper.Stop)
+
+	// учет переходов пишется в фоне пачками
+	var serviceOpts []service.Option
+	var tracker *analytics.Tracker
+	if cfg.ClickTracking {
+		if cfg.ClickIPSalt == "" {
+			slog.Warn("CLICK_IP_SALT is not set, client IPs are hashed without salt")
+		}
+		tracker = analytics.NewTracker(clicks, analytics.Config{
+			BufferSize:    cfg.ClickBufferSize,
+			FlushInterval: cfg.ClickFlushInterval,
+			IPSalt:        cfg.ClickIPSalt,
+		})
+		// дописываем переходы, оставшиеся в очереди
+		atExit(func() { tracker.Close() })
+		serviceOpts = append(serviceOpts, service.WithTracker(tracker))
+		if registry != nil {
+			registry.Register(metrics.NewCounterFunc("shorturl_clicks_dropped_total",
+				"Number of clicks dropped because the tracker queue was full.",
+				func() float64 { return float64(tracker.Dropped()) }))
+		}
+		slog.Info("click tracking enabled")
 	}
 
-	defer cleanup()
+	serviceOpts = append(serviceOpts, service.WithRedirectStatus(cfg.RedirectStatus))
+
+	generator, err := newGenerator(cfg, sequence)
+	if err != nil {
+		fatal("failed to set up code generator", "error", err)
+	}
+	serviceOpts = append(serviceOpts, service.WithGenerator(generator))
+	slog.Info("short codes", "generator", cfg.CodeGenerator)
+
+	// коды и алиасы без нецензурных слов
+	if len(cfg.WordFilterFiles) > 0 {
+		words, err := shortener.LoadWordFilter(cfg.WordFilterFiles...)
+		if err != nil {
+			fatal("failed to load word filter", "error", err)
+		}
+		if ignored := words.Ignored(); len(ignored) > 0 {
+			slog.Warn("word filter ignores words that cannot be written in Latin letters", "words", ignored)
+		}
+		serviceOpts = append(serviceOpts, service.WithWordFilter(words))
+		slog.Info("word filter enabled", "words", words.Len())
+	}
+
+	// один адрес в разной записи должен получать один код
+	if cfg.NormalizeURLs {
+		serviceOpts = append(serviceOpts, service.WithNormalization(cfg.NormalizeOptions()))
+	}
+
+	// политика адресов: списки доменов перечитываются при изменении файлов
+	urlPolicy, err := policy.New(cfg.URLPolicy())
+	if err != nil {
+		fatal("failed to load URL policy", "error", err)
+	}
+	urlPolicy.Start()
+	atExit(urlPolicy.Stop)
+	serviceOpts = append(serviceOpts, service.WithPolicy(urlPolicy))
+
+	// ссылки на наш домен и петли через другие сокращатели
+	guard, err := loopguard.New(cfg.LoopGuard(), loopguard.NewClient(cfg.ShortenerTimeout))
+	if err != nil {
+		fatal("failed to set up loop guard", "error", err)
+	}
+	serviceOpts = append(serviceOpts, service.WithLoopGuard(guard))
 
 	// инициализация юрлсервиса
-	urlService := service.NewURLService(repo)
+	urlService := service.NewURLService(repo, serviceOpts...)
 
 	// инициализация хендлера
-	urlHandler := handler.NewURLHandler(urlService, cfg.BaseURL)
+	// X-Forwarded-For учитывается только от доверенных прокси
+	trusted, err := ratelimit.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		fatal("failed to parse trusted proxies", "error", err)
+	}
+	clientIP := ratelimit.NewClientIP(trusted)
+
+	urlHandler := handler.NewURLHandler(urlService, cfg.BaseURL, handler.WithClientIP(clientIP))
+
+	// readiness проверяет хранилище и падает сразу после SIGTERM
+	health := handler.NewHealthHandler()
+	health.AddCheck("repository", repo.Ping)
+
+	// управление ссылками по API ключам, редиректы публичные
+	var authn *auth.Authenticator
+	if cfg.AuthEnabled {
+		if cfg.AdminAPIKey == "" {
+			// без админского ключа выпустить первый ключ некому, и сервис
+			// отвечал бы 401 на все, кроме редиректов
+			ok, err := hasActiveKey(keys)
+			if err != nil {
+				fatal("failed to list API keys", "error", err)
+			}
+			if !ok {
+				fatal("ADMIN_API_KEY is required while no API keys are stored, set it or AUTH_ENABLED=false")
+			}
+			slog.Warn("ADMIN_API_KEY is not set, only keys already stored can be used")
+		}
+		authn = auth.NewAuthenticator(keys, cfg.AdminAPIKey)
+		slog.Info("API key authentication enabled")
+	} else {
+		slog.Warn("API key authentication disabled, management endpoints are open")
+	}
+
+	// лимиты считаются по API ключу, а без него по адресу клиента
+	var limits *handler.RateLimits
+	if cfg.RateLimitEnabled {
+		limits = &handler.RateLimits{
+			Shorten:  ratelimit.NewLimiter(cfg.RateLimitShorten, clientIP.Key),
+			API:      ratelimit.NewLimiter(cfg.RateLimitAPI, clientIP.Key),
+			Redirect: ratelimit.NewLimiter(cfg.RateLimitRedirect, clientIP.Key),
+			Batch:    ratelimit.NewLimiter(cfg.RateLimitBatch, clientIP.Key),
+			IP:       ratelimit.NewLimiter(cfg.RateLimitIP, clientIP.Key),
+		}
+		slog.Info("rate limiting enabled")
+	}
 
-	mux := handler.SetupRoutes(urlHandler)
+	routes := handler.SetupRoutes(urlHandler, health, authn, limits)
+
+	// /metrics отдельным роутом, остальное в мукс хендлера
+	if registry != nil {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", registry.Handler())
+		mux.Handle("/", routes)
+		routes = metrics.NewHTTPMetrics(registry).Middleware(mux)
+		slog.Info("metrics available at /metrics")
+	}
+
+	// логгер запросов самый внешний, чтобы request ID был у всех роутов
+	routes = logging.NewRequestLogger(logger).Middleware(routes)
 
 	// Создание сервера
 	server := &http.Server{
 		Addr:         ":" + cfg.ServerPort,
-		Handler:      mux,
+		Handler:      routes,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -86,25 +313,100 @@ func main() {
 
 	// старт сервера в горутине
 	go func() {
-		log.Printf("Server listening on port %s", cfg.ServerPort)
+		slog.Info("server listening", "port", cfg.ServerPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
+			fatal("server failed", "error", err)
 		}
 	}()
-	
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	// сначала снимаемся с балансировщика, потом перестаем принимать соединения
+	health.Drain()
+	if cfg.ShutdownDrainDelay > 0 {
+		slog.Info("readiness failing, draining", "delay", cfg.ShutdownDrainDelay)
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+
+	slog.Info("shutting down server")
 	// даем серверу 30 сек чтобы выключится
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		slog.Error("server forced to shutdown", "error", err)
 	}
 
-	log.Println("Server stopped")
+	runCleanups()
+
+	slog.Info("server stopped")
+}
+
+// cleanups останавливают фоновые задачи и закрывают хранилище. Выполняются
+// в обратном порядке, так что хранилище закрывается последним
+var (
+	cleanupMu sync.Mutex
+	cleanups  []func()
+)
+
+// atExit регистрирует действие, которое нужно выполнить при завершении
+func atExit(f func()) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+
+	cleanups = append(cleanups, f)
+}
+
+// runCleanups выполняет зарегистрированные действия, каждое один раз
+func runCleanups() {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	cleanups = nil
+}
+
+// fatal логирует ошибку и завершает процесс. os.Exit пропускает defer,
+// поэтому закрытие делается явно: иначе пропадут финальный снапшот и
+// переходы из очереди
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	runCleanups()
+	os.Exit(1)
+}
+
+// hasActiveKey есть ли в хранилище хоть один неотозванный ключ
+func hasActiveKey(keys repository.KeyRepository) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stored, err := keys.ListKeys(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range stored {
+		if !key.Revoked() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newGenerator выбирает генератор кодов по конфигу, счетчик берется из хранилища
+func newGenerator(cfg *config.Config, sequence repository.SequenceRepository) (shortener.Generator, error) {
+	switch cfg.CodeGenerator {
+	case shortener.GeneratorRandom:
+		return shortener.RandomGenerator{}, nil
+	case shortener.GeneratorSequential:
+		return shortener.NewSequentialGenerator(sequence), nil
+	case shortener.GeneratorObfuscated:
+		return shortener.NewObfuscatedGenerator(sequence, cfg.CodeSecret)
+	default:
+		return shortener.HashGenerator{}, nil
+	}
 }
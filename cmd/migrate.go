@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"shortURL/internal/config"
+	"shortURL/internal/repository/postgres"
+)
+
+const migrateUsage = "usage: shorturl migrate [up | down [steps] | status]"
+
+// runMigrate выполняет подкоманду migrate
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.StorageType != "postgres" {
+		return fmt.Errorf("migrations require STORAGE_TYPE=postgres, got %s", cfg.StorageType)
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	pgRepo, err := postgres.NewPostgresRepository(cfg.PostgresConnectionString())
+	if err != nil {
+		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	defer pgRepo.Close()
+
+	migrator, err := pgRepo.Migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, version := range applied {
+			slog.Info("applied migration", "version", version)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			slog.Info("schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps: %s", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, version := range reverted {
+			slog.Info("reverted migration", "version", version)
+		}
+		if err != nil {
+			return err
+		}
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%03d_%s\t%s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+
+	return nil
+}
@@ -2,4 +2,14 @@ module shortURL
 
 go 1.24
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/lib/pq v1.10.9
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/net v0.34.0
+	golang.org/x/sync v0.10.0
+)
+
+require (
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
@@ -0,0 +1,179 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"shortURL/internal/repository"
+)
+
+// Config настройки записи переходов
+type Config struct {
+	// BufferSize сколько переходов может ждать записи, лишние отбрасываются
+	BufferSize int
+
+	// BatchSize максимальный размер пачки для одной записи в хранилище
+	BatchSize int
+
+	// FlushInterval как часто сбрасывать неполную пачку
+	FlushInterval time.Duration
+
+	// IPSalt соль для хеширования адресов клиентов
+	IPSalt string
+}
+
+var DefaultConfig = Config{
+	BufferSize:    1024,
+	BatchSize:     100,
+	FlushInterval: time.Second,
+}
+
+// Visit данные о переходе, собранные из запроса
+type Visit struct {
+	ShortCode string
+	Time      time.Time
+	Referrer  string
+	UserAgent string
+	ClientIP  string
+}
+
+// Tracker асинхронно пишет переходы в хранилище пачками,
+// чтобы не добавлять задержку к редиректу
+type Tracker struct {
+	store repository.ClickRepository
+	cfg   Config
+
+	mu      sync.RWMutex
+	closed  bool
+	clicks  chan repository.Click
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+func NewTracker(store repository.ClickRepository, cfg Config) *Tracker {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultConfig.BufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultConfig.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultConfig.FlushInterval
+	}
+
+	t := &Tracker{
+		store:  store,
+		cfg:    cfg,
+		clicks: make(chan repository.Click, cfg.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go t.run()
+
+	return t
+}
+
+// Record ставит переход в очередь на запись, не блокируясь.
+// Если очередь переполнена, переход отбрасывается
+func (t *Tracker) Record(v Visit) {
+	click := repository.Click{
+		ShortCode: v.ShortCode,
+		ClickedAt: v.Time,
+		Referrer:  v.Referrer,
+		UserAgent: v.UserAgent,
+		IPHash:    HashIP(v.ClientIP, t.cfg.IPSalt),
+	}
+	if click.ClickedAt.IsZero() {
+		click.ClickedAt = time.Now()
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		t.dropped.Add(1)
+		return
+	}
+
+	select {
+	case t.clicks <- click:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Stats возвращает статистику переходов по ссылке
+func (t *Tracker) Stats(ctx context.Context, shortCode string, since time.Time) (*repository.ClickStats, error) {
+	return t.store.ClickStats(ctx, shortCode, since)
+}
+
+// Dropped сколько переходов было отброшено из-за переполнения очереди
+func (t *Tracker) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// Close перестает принимать переходы и дописывает то, что осталось в очереди
+func (t *Tracker) Close() error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.clicks)
+	}
+	t.mu.Unlock()
+
+	<-t.done
+	return nil
+}
+
+func (t *Tracker) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]repository.Click, 0, t.cfg.BatchSize)
+	for {
+		select {
+		case click, ok := <-t.clicks:
+			if !ok {
+				t.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= t.cfg.BatchSize {
+				t.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			t.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (t *Tracker) flush(batch []repository.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := t.store.SaveClicks(ctx, batch); err != nil {
+		slog.Error("failed to save clicks", "count", len(batch), "error", err)
+	}
+}
+
+// HashIP хеширует адрес клиента с солью, чтобы считать уникальных посетителей
+func HashIP(ip, salt string) string {
+	if ip == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(salt + "|" + ip))
+	return hex.EncodeToString(hash[:])
+}
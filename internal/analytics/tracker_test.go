@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortURL/internal/repository/memory"
+)
+
+func TestTracker_RecordAndClose(t *testing.T) {
+	store := memory.NewMemoryRepository()
+	tracker := NewTracker(store, Config{BufferSize: 100, BatchSize: 10, FlushInterval: time.Hour, IPSalt: "salt"})
+
+	for i := 0; i < 25; i++ {
+		ip := "10.0.0.1"
+		if i%2 == 0 {
+			ip = "10.0.0.2"
+		}
+		tracker.Record(Visit{ShortCode: "abc123XYZ_", ClientIP: ip, UserAgent: "test"})
+	}
+
+	// Close дописывает неполную пачку
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	stats, err := tracker.Stats(context.Background(), "abc123XYZ_", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Stats() failed: %v", err)
+	}
+	if stats.Total != 25 {
+		t.Errorf("Total = %d, want 25", stats.Total)
+	}
+	if stats.UniqueVisitors != 2 {
+		t.Errorf("UniqueVisitors = %d, want 2", stats.UniqueVisitors)
+	}
+
+	// после Close переходы отбрасываются
+	tracker.Record(Visit{ShortCode: "abc123XYZ_"})
+	if tracker.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", tracker.Dropped())
+	}
+}
+
+func TestTracker_FlushInterval(t *testing.T) {
+	store := memory.NewMemoryRepository()
+	tracker := NewTracker(store, Config{BufferSize: 10, BatchSize: 100, FlushInterval: 5 * time.Millisecond})
+	defer tracker.Close()
+
+	tracker.Record(Visit{ShortCode: "abc123XYZ_", ClientIP: "10.0.0.1"})
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		stats, _ := store.ClickStats(context.Background(), "abc123XYZ_", time.Time{})
+		if stats.Total == 1 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("click was not flushed by interval")
+}
+
+func TestHashIP(t *testing.T) {
+	if HashIP("10.0.0.1", "a") == "10.0.0.1" {
+		t.Error("HashIP() returned raw address")
+	}
+	if HashIP("10.0.0.1", "a") != HashIP("10.0.0.1", "a") {
+		t.Error("HashIP() is not deterministic")
+	}
+	if HashIP("10.0.0.1", "a") == HashIP("10.0.0.1", "b") {
+		t.Error("HashIP() ignores salt")
+	}
+	if HashIP("", "a") != "" {
+		t.Error("HashIP() of empty address should be empty")
+	}
+}
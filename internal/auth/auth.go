@@ -0,0 +1,158 @@
+// Package auth выдает API ключи и проверяет их в запросах
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"shortURL/internal/repository"
+)
+
+var (
+	ErrUnauthorized = errors.New("invalid or revoked API key")
+	ErrForbidden    = errors.New("admin API key required")
+)
+
+// AdminKeyID id, под которым действует ключ администратора из конфига
+const AdminKeyID = "admin"
+
+const (
+	keyIDBytes     = 6
+	keySecretBytes = 32
+)
+
+// Principal владелец ключа, от имени которого выполняется запрос
+type Principal struct {
+	KeyID string
+	Name  string
+	Admin bool
+}
+
+type contextKey struct{}
+
+// WithPrincipal кладет владельца ключа в контекст запроса
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext достает владельца ключа, false если запрос без аутентификации
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok
+}
+
+// Authenticator проверяет ключи и управляет ими.
+// Ключ имеет вид <id>.<secret>, хранится только sha256 от секрета:
+// секрет случайный и длинный, медленный хэш ему не нужен
+type Authenticator struct {
+	keys repository.KeyRepository
+
+	// adminHash хэш ключа администратора из конфига, пустой если не задан
+	adminHash []byte
+
+	now func() time.Time
+}
+
+func NewAuthenticator(keys repository.KeyRepository, adminToken string) *Authenticator {
+	a := &Authenticator{keys: keys, now: time.Now}
+	if adminToken != "" {
+		sum := sha256.Sum256([]byte(adminToken))
+		a.adminHash = sum[:]
+	}
+	return a
+}
+
+// Authenticate находит владельца по ключу из заголовка Authorization
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+
+	if a.adminHash != nil {
+		sum := sha256.Sum256([]byte(token))
+		if subtle.ConstantTimeCompare(sum[:], a.adminHash) == 1 {
+			return &Principal{KeyID: AdminKeyID, Name: AdminKeyID, Admin: true}, nil
+		}
+	}
+
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrUnauthorized
+	}
+
+	key, err := a.keys.GetKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUnauthorized
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 || key.Revoked() {
+		return nil, ErrUnauthorized
+	}
+
+	return &Principal{KeyID: key.ID, Name: key.Name, Admin: key.Admin}, nil
+}
+
+// CreateKey выпускает новый ключ, открытый ключ возвращается только здесь
+func (a *Authenticator) CreateKey(ctx context.Context, name string, admin bool) (string, *repository.APIKey, error) {
+	for {
+		id, err := randomString(keyIDBytes, hex.EncodeToString)
+		if err != nil {
+			return "", nil, err
+		}
+		secret, err := randomString(keySecretBytes, base64.RawURLEncoding.EncodeToString)
+		if err != nil {
+			return "", nil, err
+		}
+
+		key := &repository.APIKey{
+			ID:         id,
+			Name:       name,
+			SecretHash: hashSecret(secret),
+			Admin:      admin,
+		}
+		err = a.keys.SaveKey(ctx, key)
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			// id совпал со старым ключом, выпускаем заново
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to save API key: %w", err)
+		}
+
+		return id + "." + secret, key, nil
+	}
+}
+
+// ListKeys возвращает все выпущенные ключи
+func (a *Authenticator) ListKeys(ctx context.Context) ([]*repository.APIKey, error) {
+	return a.keys.ListKeys(ctx)
+}
+
+// RevokeKey отзывает ключ, ссылки владельца остаются
+func (a *Authenticator) RevokeKey(ctx context.Context, id string) error {
+	return a.keys.RevokeKey(ctx, id, a.now().UTC())
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return encode(b), nil
+}
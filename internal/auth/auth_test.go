@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shortURL/internal/repository/memory"
+)
+
+const testAdminToken = "admin-token-that-is-long-enough-for-tests"
+
+func TestAuthenticator(t *testing.T) {
+	keys := memory.NewMemoryRepository()
+	authn := NewAuthenticator(keys, testAdminToken)
+	ctx := context.Background()
+
+	token, key, err := authn.CreateKey(ctx, "marketing", false)
+	if err != nil {
+		t.Fatalf("CreateKey() failed: %v", err)
+	}
+	if !strings.HasPrefix(token, key.ID+".") {
+		t.Errorf("token %q does not start with key id %q", token, key.ID)
+	}
+	if strings.Contains(key.SecretHash, strings.TrimPrefix(token, key.ID+".")) {
+		t.Errorf("secret is stored in plain text")
+	}
+
+	principal, err := authn.Authenticate(ctx, token)
+	if err != nil {
+		t.Fatalf("Authenticate() failed: %v", err)
+	}
+	if principal.KeyID != key.ID || principal.Name != "marketing" || principal.Admin {
+		t.Errorf("Authenticate() = %+v", principal)
+	}
+
+	principal, err = authn.Authenticate(ctx, testAdminToken)
+	if err != nil || !principal.Admin || principal.KeyID != AdminKeyID {
+		t.Errorf("Authenticate(admin) = %+v, %v", principal, err)
+	}
+
+	invalid := []string{"", "garbage", key.ID + ".wrong", "missing.secret", key.ID + "."}
+	for _, token := range invalid {
+		if _, err := authn.Authenticate(ctx, token); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Authenticate(%q) error = %v, want %v", token, err, ErrUnauthorized)
+		}
+	}
+
+	if err := authn.RevokeKey(ctx, key.ID); err != nil {
+		t.Fatalf("RevokeKey() failed: %v", err)
+	}
+	if _, err := authn.Authenticate(ctx, token); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Authenticate() with revoked key error = %v, want %v", err, ErrUnauthorized)
+	}
+
+	// без ключа администратора в конфиге пустой токен не совпадает с ним
+	if _, err := NewAuthenticator(keys, "").Authenticate(ctx, ""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Authenticate(\"\") without admin key error = %v, want %v", err, ErrUnauthorized)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	authn := NewAuthenticator(memory.NewMemoryRepository(), testAdminToken)
+	token, _, _ := authn.CreateKey(context.Background(), "user", false)
+
+	var seen *Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+	})
+	handler := authn.Middleware(RequireAdmin(next))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + token, http.StatusUnauthorized},
+		{"user key", "Bearer " + token, http.StatusForbidden},
+		{"admin key", "bearer " + testAdminToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/admin/keys", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("WWW-Authenticate header is missing")
+			}
+		})
+	}
+
+	if seen == nil || !seen.Admin {
+		t.Errorf("principal in context = %+v, want admin", seen)
+	}
+}
@@ -2,36 +2,293 @@ package config
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"shortURL/internal/logging"
+	"shortURL/internal/loopguard"
+	"shortURL/internal/policy"
+	"shortURL/internal/ratelimit"
+	"shortURL/internal/repository"
+	"shortURL/pkg/shortener"
+	"shortURL/pkg/urlnorm"
 )
 
+// minAdminKeyLength минимальная длина ADMIN_API_KEY
+const minAdminKeyLength = 32
+
 type Config struct {
 	ServerPort string
 	BaseURL    string
 
-	// Переключатель между in memory и бд
+	// код редиректа для ссылок, созданных без redirect_status
+	RedirectStatus int
+
+	// сколько отдавать failing на /readyz перед остановкой сервера
+	ShutdownDrainDelay time.Duration
+
+	// формат логов text или json и минимальный уровень
+	LogFormat string
+	LogLevel  string
+
+	// Переключатель между in memory, файлом и бд
 	StorageType string
 
+	// путь к файлу базы для STORAGE_TYPE=file
+	FilePath string
+
+	// сохранение in memory хранилища на диск, пустой путь отключает
+	MemorySnapshotPath     string
+	MemorySnapshotInterval time.Duration
+	MemoryFsync            string
+
+	// кэш ссылок перед хранилищем
+	CacheEnabled     bool
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+
+	// API ключи на управление ссылками, ключ администратора задается вне базы
+	AuthEnabled bool
+	AdminAPIKey string
+
+	// ограничение частоты запросов на клиента, Rate в запросах в секунду,
+	// у RateLimitBatch в ссылках пакета. RateLimitIP считается по адресу до
+	// проверки API ключа
+	RateLimitEnabled  bool
+	RateLimitShorten  ratelimit.Limit
+	RateLimitAPI      ratelimit.Limit
+	RateLimitRedirect ratelimit.Limit
+	RateLimitBatch    ratelimit.Limit
+	RateLimitIP       ratelimit.Limit
+	// адреса и подсети прокси, которым верим в X-Forwarded-For
+	TrustedProxies string
+
+	// отдавать метрики Prometheus на /metrics
+	MetricsEnabled bool
+
+	// нормализация URL перед дедупликацией
+	NormalizeURLs          bool
+	NormalizeSortQuery     bool
+	NormalizeStripTracking bool
+	NormalizeDropFragment  bool
+
+	// политика адресов назначения
+	BlocklistFile         string
+	AllowlistFile         string
+	PolicyReloadInterval  time.Duration
+	PolicyAllowPrivateIPs bool
+	PolicyResolveHosts    bool
+	PolicyAllowedPorts    []int
+	PolicyAllowUserinfo   bool
+
+	// ссылки на наш домен и петли через другие сокращатели. Хост из
+	// BASE_URL считается нашим всегда, SelfHosts добавляет другие
+	SelfHosts        []string
+	SelfReference    string
+	FollowShorteners bool
+	ShortenerHosts   []string
+	ShortenerTimeout time.Duration
+	RedirectMaxHops  int
+
+	// способ генерации кодов и ключ перестановки для obfuscated
+	CodeGenerator string
+	CodeSecret    string
+
+	// длина и алфавит новых кодов, алфавит по имени или строкой символов.
+	// Коды прежних форматов продолжают открываться
+	CodeLength          int
+	CodeAlphabet        string
+	CodeChecksum        bool
+	PreviousCodeFormats []shortener.CodeFormat
+
+	// файлы или каталоги со словами, которых не должно быть в кодах и алиасах
+	WordFilterFiles []string
+
+	// ограничения на пользовательские алиасы
+	AliasMinLength int
+	AliasMaxLength int
+	AliasCharset   string
+
+	// как часто удалять истекшие ссылки
+	SweepInterval time.Duration
+
+	// учет переходов по ссылкам
+	ClickTracking      bool
+	ClickBufferSize    int
+	ClickFlushInterval time.Duration
+	ClickIPSalt        string
+
 	// конфигураци для бд
 	PostgresHost     string
 	PostgresPort     string
 	PostgresUser     string
 	PostgresPassword string
 	PostgresDB       string
+
+	// накатывать миграции при старте сервера
+	MigrateOnStart bool
 }
 
 // Load загружает конфиги из env/берет дефолтные
 func Load() (*Config, error) {
 	cfg := &Config{
-		ServerPort:       getEnv("SERVER_PORT", "8080"),
-		BaseURL:          getEnv("BASE_URL", "http://localhost:8080"),
-		StorageType:      getEnv("STORAGE_TYPE", "memory"),
-		PostgresHost:     getEnv("POSTGRES_HOST", "localhost"),
-		PostgresPort:     getEnv("POSTGRES_PORT", "5432"),
-		PostgresUser:     getEnv("POSTGRES_USER", "postgres"),
-		PostgresPassword: getEnv("POSTGRES_PASSWORD", "postgres"),
-		PostgresDB:       getEnv("POSTGRES_DB", "shorturl"),
+		ServerPort:         getEnv("SERVER_PORT", "8080"),
+		BaseURL:            getEnv("BASE_URL", "http://localhost:8080"),
+		LogFormat:          getEnv("LOG_FORMAT", logging.FormatText),
+		LogLevel:           getEnv("LOG_LEVEL", "info"),
+		StorageType:        getEnv("STORAGE_TYPE", "memory"),
+		FilePath:           getEnv("FILE_PATH", "shorturl.db"),
+		MemorySnapshotPath: getEnv("MEMORY_SNAPSHOT_PATH", ""),
+		MemoryFsync:        getEnv("MEMORY_FSYNC", "interval"),
+		AliasCharset:       getEnv("ALIAS_CHARSET", shortener.DefaultAliasPolicy.Charset),
+		CodeGenerator:      getEnv("CODE_GENERATOR", shortener.GeneratorHash),
+		CodeSecret:         getEnv("CODE_SECRET", ""),
+		CodeAlphabet:       getEnv("CODE_ALPHABET", "default"),
+		ClickIPSalt:        getEnv("CLICK_IP_SALT", ""),
+		AdminAPIKey:        getEnv("ADMIN_API_KEY", ""),
+		TrustedProxies:     getEnv("TRUSTED_PROXIES", ""),
+		BlocklistFile:      getEnv("POLICY_BLOCKLIST_FILE", ""),
+		AllowlistFile:      getEnv("POLICY_ALLOWLIST_FILE", ""),
+		WordFilterFiles:    getEnvStrings("WORD_FILTER_FILES", nil),
+		SelfHosts:          getEnvStrings("SELF_HOSTS", nil),
+		SelfReference:      getEnv("SELF_REFERENCE", loopguard.ModeResolve),
+		ShortenerHosts:     getEnvStrings("SHORTENER_HOSTS", loopguard.DefaultShortenerHosts),
+		PostgresHost:       getEnv("POSTGRES_HOST", "localhost"),
+		PostgresPort:       getEnv("POSTGRES_PORT", "5432"),
+		PostgresUser:       getEnv("POSTGRES_USER", "postgres"),
+		PostgresPassword:   getEnv("POSTGRES_PASSWORD", "postgres"),
+		PostgresDB:         getEnv("POSTGRES_DB", "shorturl"),
+	}
+
+	var err error
+	if cfg.RedirectStatus, err = getEnvInt("REDIRECT_STATUS", http.StatusFound); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownDrainDelay, err = getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.CodeLength, err = getEnvInt("CODE_LENGTH", shortener.ShortURLLength); err != nil {
+		return nil, err
+	}
+	if cfg.CodeChecksum, err = getEnvBool("CODE_CHECKSUM", false); err != nil {
+		return nil, err
+	}
+	if cfg.PreviousCodeFormats, err = getEnvCodeFormats("CODE_PREVIOUS_FORMATS"); err != nil {
+		return nil, err
+	}
+
+	if cfg.AliasMinLength, err = getEnvInt("ALIAS_MIN_LENGTH", shortener.DefaultAliasPolicy.MinLength); err != nil {
+		return nil, err
+	}
+	if cfg.AliasMaxLength, err = getEnvInt("ALIAS_MAX_LENGTH", shortener.DefaultAliasPolicy.MaxLength); err != nil {
+		return nil, err
+	}
+
+	if cfg.MemorySnapshotInterval, err = getEnvDuration("MEMORY_SNAPSHOT_INTERVAL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.CacheEnabled, err = getEnvBool("CACHE_ENABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.CacheSize, err = getEnvInt("CACHE_SIZE", 10000); err != nil {
+		return nil, err
+	}
+	if cfg.CacheTTL, err = getEnvDuration("CACHE_TTL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.CacheNegativeTTL, err = getEnvDuration("CACHE_NEGATIVE_TTL", 10*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.AuthEnabled, err = getEnvBool("AUTH_ENABLED", true); err != nil {
+		return nil, err
+	}
+
+	if cfg.RateLimitEnabled, err = getEnvBool("RATE_LIMIT_ENABLED", true); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitShorten, err = getEnvLimit("RATE_LIMIT_SHORTEN", ratelimit.Limit{Rate: 1, Burst: 20}); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitAPI, err = getEnvLimit("RATE_LIMIT_API", ratelimit.Limit{Rate: 5, Burst: 50}); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitRedirect, err = getEnvLimit("RATE_LIMIT_REDIRECT", ratelimit.Limit{Rate: 50, Burst: 100}); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitBatch, err = getEnvLimit("RATE_LIMIT_BATCH", ratelimit.Limit{Rate: 20, Burst: 1000}); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitIP, err = getEnvLimit("RATE_LIMIT_IP", ratelimit.Limit{Rate: 10, Burst: 100}); err != nil {
+		return nil, err
+	}
+
+	if cfg.MetricsEnabled, err = getEnvBool("METRICS_ENABLED", true); err != nil {
+		return nil, err
+	}
+
+	if cfg.NormalizeURLs, err = getEnvBool("URL_NORMALIZE", true); err != nil {
+		return nil, err
+	}
+	if cfg.NormalizeSortQuery, err = getEnvBool("URL_NORMALIZE_SORT_QUERY", false); err != nil {
+		return nil, err
+	}
+	if cfg.NormalizeStripTracking, err = getEnvBool("URL_NORMALIZE_STRIP_TRACKING", false); err != nil {
+		return nil, err
+	}
+	if cfg.NormalizeDropFragment, err = getEnvBool("URL_NORMALIZE_DROP_FRAGMENT", true); err != nil {
+		return nil, err
+	}
+
+	if cfg.PolicyReloadInterval, err = getEnvDuration("POLICY_RELOAD_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.PolicyAllowPrivateIPs, err = getEnvBool("POLICY_ALLOW_PRIVATE_IPS", false); err != nil {
+		return nil, err
+	}
+	if cfg.PolicyResolveHosts, err = getEnvBool("POLICY_RESOLVE_HOSTS", false); err != nil {
+		return nil, err
+	}
+	if cfg.PolicyAllowedPorts, err = getEnvInts("POLICY_ALLOWED_PORTS", []int{80, 443, 8080, 8443}); err != nil {
+		return nil, err
+	}
+	if cfg.PolicyAllowUserinfo, err = getEnvBool("POLICY_ALLOW_USERINFO", false); err != nil {
+		return nil, err
+	}
+
+	if cfg.FollowShorteners, err = getEnvBool("FOLLOW_SHORTENERS", false); err != nil {
+		return nil, err
+	}
+	if cfg.ShortenerTimeout, err = getEnvDuration("SHORTENER_TIMEOUT", 3*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.RedirectMaxHops, err = getEnvInt("REDIRECT_MAX_HOPS", 5); err != nil {
+		return nil, err
+	}
+
+	if cfg.SweepInterval, err = getEnvDuration("SWEEP_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.ClickTracking, err = getEnvBool("CLICK_TRACKING", true); err != nil {
+		return nil, err
+	}
+	if cfg.ClickBufferSize, err = getEnvInt("CLICK_BUFFER_SIZE", 1024); err != nil {
+		return nil, err
+	}
+	if cfg.ClickFlushInterval, err = getEnvDuration("CLICK_FLUSH_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.MigrateOnStart, err = getEnvBool("MIGRATE_ON_START", true); err != nil {
+		return nil, err
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -42,7 +299,7 @@ func Load() (*Config, error) {
 }
 
 func (c *Config) Validate() error {
-	if c.StorageType != "memory" && c.StorageType != "postgres" {
+	if c.StorageType != "memory" && c.StorageType != "postgres" && c.StorageType != "file" {
 		return fmt.Errorf("invalid storage type: %s ", c.StorageType)
 	}
 
@@ -50,6 +307,126 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid server port: %s", c.ServerPort)
 	}
 
+	if !repository.ValidRedirectStatus(c.RedirectStatus) {
+		return fmt.Errorf("invalid redirect status: %d", c.RedirectStatus)
+	}
+
+	if c.LogFormat != logging.FormatText && c.LogFormat != logging.FormatJSON {
+		return fmt.Errorf("invalid log format: %s", c.LogFormat)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return err
+	}
+
+	if c.ShutdownDrainDelay < 0 {
+		return fmt.Errorf("shutdown drain delay must not be negative")
+	}
+
+	if c.AuthEnabled && c.AdminAPIKey != "" && len(c.AdminAPIKey) < minAdminKeyLength {
+		return fmt.Errorf("admin API key must be at least %d characters", minAdminKeyLength)
+	}
+
+	if c.RateLimitEnabled {
+		if err := c.RateLimitShorten.Check(); err != nil {
+			return fmt.Errorf("invalid shorten rate limit: %w", err)
+		}
+		if err := c.RateLimitAPI.Check(); err != nil {
+			return fmt.Errorf("invalid api rate limit: %w", err)
+		}
+		if err := c.RateLimitRedirect.Check(); err != nil {
+			return fmt.Errorf("invalid redirect rate limit: %w", err)
+		}
+		if err := c.RateLimitBatch.Check(); err != nil {
+			return fmt.Errorf("invalid batch rate limit: %w", err)
+		}
+		if err := c.RateLimitIP.Check(); err != nil {
+			return fmt.Errorf("invalid ip rate limit: %w", err)
+		}
+	}
+	if _, err := ratelimit.ParseTrustedProxies(c.TrustedProxies); err != nil {
+		return err
+	}
+
+	if c.PolicyReloadInterval < 0 {
+		return fmt.Errorf("policy reload interval must not be negative")
+	}
+	for _, port := range c.PolicyAllowedPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid allowed port: %d", port)
+		}
+	}
+
+	if c.SelfReference != loopguard.ModeReject && c.SelfReference != loopguard.ModeResolve {
+		return fmt.Errorf("invalid self reference mode: %s", c.SelfReference)
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid base URL: %s", c.BaseURL)
+	}
+	if c.RedirectMaxHops < 1 {
+		return fmt.Errorf("redirect max hops must be positive")
+	}
+	if c.FollowShorteners && c.ShortenerTimeout <= 0 {
+		return fmt.Errorf("shortener timeout must be positive")
+	}
+
+	switch c.CodeGenerator {
+	case shortener.GeneratorHash, shortener.GeneratorRandom, shortener.GeneratorSequential:
+	case shortener.GeneratorObfuscated:
+		if len(c.CodeSecret) < shortener.MinSecretLength {
+			return fmt.Errorf("code secret must be at least %d characters", shortener.MinSecretLength)
+		}
+	default:
+		return fmt.Errorf("invalid code generator: %s", c.CodeGenerator)
+	}
+
+	if err := c.CodeFormat().Check(); err != nil {
+		return fmt.Errorf("invalid code format: %w", err)
+	}
+	for _, f := range c.PreviousCodeFormats {
+		if err := f.Check(); err != nil {
+			return fmt.Errorf("invalid previous code format: %w", err)
+		}
+	}
+
+	if err := c.AliasPolicy().Check(); err != nil {
+		return fmt.Errorf("invalid alias policy: %w", err)
+	}
+
+	if c.SweepInterval <= 0 {
+		return fmt.Errorf("sweep interval must be positive")
+	}
+
+	if c.ClickTracking {
+		if c.ClickBufferSize <= 0 {
+			return fmt.Errorf("click buffer size must be positive")
+		}
+		if c.ClickFlushInterval <= 0 {
+			return fmt.Errorf("click flush interval must be positive")
+		}
+	}
+
+	if c.StorageType == "file" && c.FilePath == "" {
+		return fmt.Errorf("file path is required")
+	}
+
+	if c.StorageType == "memory" && c.MemorySnapshotPath != "" {
+		if c.MemorySnapshotInterval <= 0 {
+			return fmt.Errorf("memory snapshot interval must be positive")
+		}
+	}
+
+	if c.CacheEnabled {
+		if c.CacheSize <= 0 {
+			return fmt.Errorf("cache size must be positive")
+		}
+		if c.CacheTTL <= 0 {
+			return fmt.Errorf("cache ttl must be positive")
+		}
+		if c.CacheNegativeTTL < 0 {
+			return fmt.Errorf("cache negative ttl must not be negative")
+		}
+	}
+
 	if c.StorageType == "postgres" {
 		if c.PostgresHost == "" {
 			return fmt.Errorf("postgres host is required")
@@ -65,6 +442,61 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// CodeFormat собирает формат новых кодов из конфига
+func (c *Config) CodeFormat() shortener.CodeFormat {
+	return shortener.CodeFormat{
+		Length:   c.CodeLength,
+		Alphabet: shortener.Alphabet(c.CodeAlphabet),
+		Checksum: c.CodeChecksum,
+	}
+}
+
+// AliasPolicy собирает политику алиасов из конфига
+func (c *Config) AliasPolicy() shortener.AliasPolicy {
+	return shortener.AliasPolicy{
+		MinLength: c.AliasMinLength,
+		MaxLength: c.AliasMaxLength,
+		Charset:   c.AliasCharset,
+	}
+}
+
+// NormalizeOptions собирает опции нормализации URL из конфига
+func (c *Config) NormalizeOptions() urlnorm.Options {
+	return urlnorm.Options{
+		SortQuery:     c.NormalizeSortQuery,
+		StripTracking: c.NormalizeStripTracking,
+		DropFragment:  c.NormalizeDropFragment,
+	}
+}
+
+// URLPolicy собирает политику адресов назначения из конфига
+func (c *Config) URLPolicy() policy.Config {
+	return policy.Config{
+		BlocklistPath:   c.BlocklistFile,
+		AllowlistPath:   c.AllowlistFile,
+		ReloadInterval:  c.PolicyReloadInterval,
+		AllowPrivateIPs: c.PolicyAllowPrivateIPs,
+		ResolveHosts:    c.PolicyResolveHosts,
+		AllowedPorts:    c.PolicyAllowedPorts,
+		AllowUserinfo:   c.PolicyAllowUserinfo,
+	}
+}
+
+// LoopGuard собирает защиту от ссылок на себя и петель из конфига
+func (c *Config) LoopGuard() loopguard.Config {
+	// BASE_URL проверен в Validate
+	base, _ := url.Parse(c.BaseURL)
+
+	return loopguard.Config{
+		SelfHosts:        append([]string{base.Hostname()}, c.SelfHosts...),
+		BasePath:         base.Path,
+		Mode:             c.SelfReference,
+		FollowShorteners: c.FollowShorteners,
+		ShortenerHosts:   c.ShortenerHosts,
+		MaxHops:          c.RedirectMaxHops,
+	}
+}
+
 // PostgresConnectionString возращает строчку подключения
 func (c *Config) PostgresConnectionString() string {
 	return fmt.Sprintf(
@@ -84,3 +516,131 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt читает целое число из env
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+
+	return n, nil
+}
+
+// getEnvBool читает флаг из env
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", key, value)
+	}
+
+	return b, nil
+}
+
+// getEnvInts читает список чисел через запятую, пустое значение дает пустой
+// список только если переменная задана
+func getEnvInts(key string, defaultValue []int) ([]int, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue, nil
+	}
+
+	var ints []int
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", key, value)
+		}
+		ints = append(ints, n)
+	}
+
+	return ints, nil
+}
+
+// getEnvStrings читает список строк через запятую, пустое значение дает
+// пустой список только если переменная задана
+func getEnvStrings(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+
+	var values []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			values = append(values, field)
+		}
+	}
+
+	return values
+}
+
+// getEnvCodeFormats читает форматы кодов через запятую в виде длина:алфавит,
+// с суффиксом :check для кодов с контрольным символом, например 10:default,8:friendly:check
+func getEnvCodeFormats(key string) ([]shortener.CodeFormat, error) {
+	var formats []shortener.CodeFormat
+	for _, field := range getEnvStrings(key, nil) {
+		length, alphabet, ok := strings.Cut(field, ":")
+		alphabet, checksum := strings.CutSuffix(alphabet, ":check")
+		n, err := strconv.Atoi(length)
+		if !ok || err != nil || alphabet == "" {
+			return nil, fmt.Errorf("invalid %s: %s", key, field)
+		}
+		formats = append(formats, shortener.CodeFormat{
+			Length:   n,
+			Alphabet: shortener.Alphabet(alphabet),
+			Checksum: checksum,
+		})
+	}
+
+	return formats, nil
+}
+
+// getEnvDuration читает длительность из env, например 30s или 5m
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+
+	return d, nil
+}
+
+// getEnvLimit читает лимит из пары KEY_RPS и KEY_BURST
+func getEnvLimit(key string, defaultValue ratelimit.Limit) (ratelimit.Limit, error) {
+	limit := defaultValue
+
+	if value := os.Getenv(key + "_RPS"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return ratelimit.Limit{}, fmt.Errorf("invalid %s_RPS: %s", key, value)
+		}
+		limit.Rate = rate
+	}
+
+	var err error
+	if limit.Burst, err = getEnvInt(key+"_BURST", defaultValue.Burst); err != nil {
+		return ratelimit.Limit{}, err
+	}
+
+	return limit, nil
+}
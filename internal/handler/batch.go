@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"shortURL/internal/ratelimit"
+	"shortURL/internal/service"
+)
+
+const (
+	// streamChunk сколько строк NDJSON копим перед сохранением, ответ на них
+	// уходит клиенту, не дожидаясь конца потока
+	streamChunk = 500
+
+	// maxStreamLine предел длины одной строки NDJSON
+	maxStreamLine = 1 << 20
+
+	// maxStreamItems предел числа строк в одном потоке NDJSON
+	maxStreamItems = 100 * service.MaxBatchSize
+
+	// maxBatchBody предел тела с JSON массивом
+	maxBatchBody = 8 << 20
+
+	// streamChunkTimeout сколько даем на чтение и обработку одной части.
+	// Таймауты сервера продлеваются по частям, иначе длинный поток оборвется
+	streamChunkTimeout = time.Minute
+)
+
+// BatchItemResult результат для одной ссылки пакета, Index ее номер во входе
+type BatchItemResult struct {
+	Index    int    `json:"index"`
+	Status   int    `json:"status"`
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// batchLine элемент входа, invalid если строку NDJSON не удалось разобрать,
+// limited если на нее не хватило токенов
+type batchLine struct {
+	req     ShortenRequest
+	invalid bool
+	limited bool
+}
+
+// ShortenBatch возвращает хендлер пакетного сокращения. Тело - JSON массив
+// ShortenRequest не длиннее service.MaxBatchSize или NDJSON до maxStreamItems
+// строк, тогда и ответ идет построчно по мере сохранения. Ошибки отдельных
+// ссылок пакет не прерывают. items списывает по токену на каждую ссылку,
+// ссылкам сверх лимита достается статус 429; nil снимает ограничение
+func (h *URLHandler) ShortenBatch(items *ratelimit.Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isNDJSON(r.Header.Get("Content-Type")) {
+			h.shortenStream(w, r, items)
+			return
+		}
+		h.shortenArray(w, r, items)
+	}
+}
+
+// shortenArray обрабатывает пакет JSON массивом за один раз
+func (h *URLHandler) shortenArray(w http.ResponseWriter, r *http.Request, items *ratelimit.Limiter) {
+	reqs, err := decodeBatch(http.MaxBytesReader(w, r.Body, maxBatchBody))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrBatchTooLarge):
+		h.sendError(w, fmt.Sprintf("batch exceeds %d items, send NDJSON instead", service.MaxBatchSize), http.StatusRequestEntityTooLarge)
+		return
+	case errors.As(err, &tooLarge):
+		h.sendError(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(reqs) == 0 {
+		h.sendError(w, "batch is empty", http.StatusBadRequest)
+		return
+	}
+
+	lines := make([]batchLine, len(reqs))
+	for i, req := range reqs {
+		lines[i].req = req
+	}
+
+	granted, res := chargeItems(items, r, lines)
+	if items != nil {
+		ratelimit.SetHeaders(w.Header(), res)
+	}
+	if granted == 0 {
+		h.sendError(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	results, err := h.shortenChunk(r.Context(), 0, lines)
+	if err != nil {
+		h.sendError(w, "failed to create short URLs", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, results, http.StatusOK)
+}
+
+// decodeBatch читает JSON массив по элементу и бросает чтение, как только
+// элементов становится больше service.MaxBatchSize
+func decodeBatch(body io.Reader) ([]ShortenRequest, error) {
+	dec := json.NewDecoder(body)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('[') {
+		return nil, errors.New("batch must be a JSON array")
+	}
+
+	var reqs []ShortenRequest
+	for dec.More() {
+		if len(reqs) == service.MaxBatchSize {
+			return nil, service.ErrBatchTooLarge
+		}
+
+		var req ShortenRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+
+	// закрывающая скобка
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return reqs, nil
+}
+
+// chargeItems списывает по токену на строку и помечает limited те, на
+// которые токенов не хватило. Возвращает число пропущенных строк
+func chargeItems(items *ratelimit.Limiter, r *http.Request, lines []batchLine) (int, ratelimit.Result) {
+	if items == nil {
+		return len(lines), ratelimit.Result{Allowed: true}
+	}
+
+	granted, res := items.AllowN(items.Key(r), len(lines))
+	for i := granted; i < len(lines); i++ {
+		lines[i].limited = true
+	}
+	return granted, res
+}
+
+// shortenStream обрабатывает NDJSON частями по streamChunk строк. После
+// первой отправленной части статус уже не поменять, поэтому сбой посреди
+// потока отдается последней строкой ErrorResponse
+func (h *URLHandler) shortenStream(w http.ResponseWriter, r *http.Request, items *ratelimit.Limiter) {
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+
+	// ответ пишется, пока тело еще читается
+	rc := http.NewResponseController(w)
+	rc.EnableFullDuplex()
+	enc := json.NewEncoder(w)
+	started := false
+	index := 0
+
+	extendDeadlines := func() {
+		deadline := time.Now().Add(streamChunkTimeout)
+		rc.SetReadDeadline(deadline)
+		rc.SetWriteDeadline(deadline)
+	}
+	extendDeadlines()
+
+	fail := func(message string, status int) {
+		if !started {
+			h.sendError(w, message, status)
+			return
+		}
+		enc.Encode(ErrorResponse{Error: message})
+	}
+
+	var chunk []batchLine
+	flush := func() bool {
+		_, res := chargeItems(items, r, chunk)
+
+		results, err := h.shortenChunk(r.Context(), index, chunk)
+		if err != nil {
+			fail("failed to create short URLs", http.StatusInternalServerError)
+			return false
+		}
+
+		if !started {
+			if items != nil {
+				ratelimit.SetHeaders(w.Header(), res)
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		for _, result := range results {
+			enc.Encode(result)
+		}
+		rc.Flush()
+		extendDeadlines()
+
+		index += len(chunk)
+		chunk = chunk[:0]
+		return true
+	}
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if index+len(chunk) == maxStreamItems {
+			// уже принятые строки сохраняем, остальное отвергаем
+			if len(chunk) > 0 && !flush() {
+				return
+			}
+			fail(fmt.Sprintf("batch exceeds %d items", maxStreamItems), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var item batchLine
+		item.invalid = json.Unmarshal(line, &item.req) != nil
+		chunk = append(chunk, item)
+
+		if len(chunk) == streamChunk && !flush() {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fail("invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(chunk) > 0 {
+		flush()
+		return
+	}
+	if !started {
+		h.sendError(w, "batch is empty", http.StatusBadRequest)
+	}
+}
+
+// shortenChunk создает ссылки одной части пакета, offset номер первой из них во входе
+func (h *URLHandler) shortenChunk(ctx context.Context, offset int, lines []batchLine) ([]BatchItemResult, error) {
+	results := make([]BatchItemResult, len(lines))
+	items := make([]service.BatchItem, 0, len(lines))
+	positions := make([]int, 0, len(lines))
+
+	for i, line := range lines {
+		results[i].Index = offset + i
+		switch {
+		case line.limited:
+			results[i].Status = http.StatusTooManyRequests
+			results[i].Error = "rate limit exceeded"
+		case line.invalid:
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "invalid request body"
+		case line.req.URL == "":
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "url is required"
+		default:
+			items = append(items, service.BatchItem{URL: line.req.URL, Options: line.req.options()})
+			positions = append(positions, i)
+		}
+	}
+
+	created, err := h.service.CreateBatch(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, c := range created {
+		result := &results[positions[j]]
+		if c.Err != nil {
+			resp, status := createError(c.Err)
+			result.Status = status
+			result.Error = resp.Error
+			result.Reason = resp.Reason
+			continue
+		}
+		result.Status = http.StatusCreated
+		result.ShortURL = h.baseURL + "/" + c.ShortCode
+	}
+
+	return results, nil
+}
+
+// isNDJSON пришел ли поток JSON объектов по одному в строке
+func isNDJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return true
+	}
+	return false
+}
@@ -4,36 +4,129 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"shortURL/internal/analytics"
+	"shortURL/internal/auth"
+	"shortURL/internal/policy"
+	"shortURL/internal/ratelimit"
 	"shortURL/internal/repository"
 	"shortURL/internal/service"
 )
 
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+
+	// permanentCacheMaxAge сколько браузер и прокси могут помнить постоянный редирект
+	permanentCacheMaxAge = 24 * time.Hour
+)
+
 type URLHandler struct {
-	service *service.URLService
-	baseURL string
+	service  *service.URLService
+	baseURL  string
+	clientIP *ratelimit.ClientIP
+}
+
+// Option настраивает URLHandler
+type Option func(*URLHandler)
+
+// WithClientIP задает, как определять адрес клиента для статистики.
+// По умолчанию прокси не доверяем и берем адрес соединения
+func WithClientIP(clientIP *ratelimit.ClientIP) Option {
+	return func(h *URLHandler) {
+		h.clientIP = clientIP
+	}
 }
 
-func NewURLHandler(service *service.URLService, baseURL string) *URLHandler {
+func NewURLHandler(service *service.URLService, baseURL string, opts ...Option) *URLHandler {
 	baseURL = strings.TrimSuffix(baseURL, "/")
 
-	return &URLHandler{
-		service: service,
-		baseURL: baseURL,
+	h := &URLHandler{
+		service:  service,
+		baseURL:  baseURL,
+		clientIP: ratelimit.NewClientIP(nil),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+
+	return h
 }
 
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL   string `json:"url"`
+	Alias string `json:"alias,omitempty"`
+
+	// время жизни задается либо абсолютно, либо в секундах от текущего момента
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+
+	// код редиректа 301, 302, 307 или 308, по умолчанию из конфига
+	RedirectStatus int `json:"redirect_status,omitempty"`
+}
+
+// options параметры создания ссылки из запроса
+func (req ShortenRequest) options() service.CreateOptions {
+	// ограничиваем до умножения, иначе большое значение переполнит Duration.
+	// Все, что больше service.MaxTTL, сервис отвергнет
+	ttl := max(min(req.TTLSeconds, int64(service.MaxTTL/time.Second)+1), -1)
+
+	return service.CreateOptions{
+		Alias:     req.Alias,
+		ExpiresAt: req.ExpiresAt,
+		TTL:       time.Duration(ttl) * time.Second,
+
+		RedirectStatus: req.RedirectStatus,
+	}
 }
 
 type ShortenResponse struct {
 	ShortURL string `json:"short_url"`
 }
 
+type UpdateRequest struct {
+	URL string `json:"url"`
+}
+
+type LinkResponse struct {
+	ShortCode string     `json:"short_code"`
+	ShortURL  string     `json:"short_url"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	Owner     string     `json:"owner,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	RedirectStatus int `json:"redirect_status,omitempty"`
+}
+
+type ListResponse struct {
+	Links      []LinkResponse `json:"links"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type StatsBucket struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+type StatsResponse struct {
+	ShortCode      string        `json:"short_code"`
+	Since          time.Time     `json:"since"`
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Daily          []StatsBucket `json:"daily"`
+	Hourly         []StatsBucket `json:"hourly"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Reason уточняет, почему адрес запрещен политикой
+	Reason string `json:"reason,omitempty"`
+	// Suggestions ссылки, которые скорее всего имелись в виду при опечатке
+	Suggestions []string `json:"suggestions,omitempty"`
 }
 
 // Shorten сохраняет оригинальный URL и возвращает short
@@ -55,13 +148,10 @@ func (h *URLHandler) Shorten(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// создание шортюрл
-	shortCode, err := h.service.Create(r.Context(), req.URL)
+	shortCode, err := h.service.CreateWithOptions(r.Context(), req.URL, req.options())
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidURL) {
-			h.sendError(w, "invalid URL format", http.StatusBadRequest)
-			return
-		}
-		h.sendError(w, "failed to create short URL", http.StatusInternalServerError)
+		resp, status := createError(err)
+		h.sendJSON(w, resp, status)
 		return
 	}
 
@@ -72,9 +162,14 @@ func (h *URLHandler) Shorten(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, resp, http.StatusCreated)
 }
 
-// Redirect принимает shortURL и возвращает ориг URL
+// Redirect принимает shortURL и возвращает ориг URL. Кроме GET принимаются
+// HEAD от проверяющих ссылки клиентов и POST, но только для ссылок с 307 и
+// 308: они повторяют его на оригинальном адресе вместе с телом
 func (h *URLHandler) Redirect(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
 		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -87,20 +182,231 @@ func (h *URLHandler) Redirect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// ищем и возвращаем оригЮРЛ по shortURL
-	originalURL, err := h.service.Resolve(r.Context(), path)
+	link, err := h.service.ResolveLink(r.Context(), path)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMistyped):
+			h.sendMistyped(w, r, path)
+		case errors.Is(err, repository.ErrNotFound):
+			h.sendError(w, "short URL not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrExpired):
+			h.sendError(w, "short URL has expired", http.StatusGone)
+		default:
+			h.sendError(w, "failed to resolve short URL", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 301 и 302 браузер превратит в GET, а POST с чужой формы только
+	// накручивал бы переходы
+	if r.Method == http.MethodPost && !preservesMethod(link.RedirectStatus) {
+		w.Header().Set("Allow", "GET, HEAD")
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// HEAD только проверяет ссылку, переходом не считается
+	if r.Method != http.MethodHead {
+		h.service.TrackVisit(analytics.Visit{
+			ShortCode: path,
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			ClientIP:  h.clientIP.Resolve(r),
+		})
+	}
+
+	w.Header().Set("Cache-Control", h.redirectCacheControl(link))
+	http.Redirect(w, r, link.OriginalURL, link.RedirectStatus)
+}
+
+// preservesMethod повторяет ли клиент запрос тем же методом после редиректа
+func preservesMethod(status int) bool {
+	return status == http.StatusTemporaryRedirect || status == http.StatusPermanentRedirect
+}
+
+// redirectCacheControl постоянный редирект можно кэшировать, но не дольше
+// жизни ссылки. Временный и учитываемый переход должен доходить до нас
+func (h *URLHandler) redirectCacheControl(link *repository.Link) string {
+	if !repository.PermanentRedirect(link.RedirectStatus) || h.service.TracksClicks() {
+		return "no-store"
+	}
+
+	maxAge := permanentCacheMaxAge
+	if link.ExpiresAt != nil {
+		maxAge = min(maxAge, time.Until(*link.ExpiresAt))
+	}
+	if maxAge < time.Second {
+		return "no-store"
+	}
+
+	return "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+}
+
+// Stats отдает статистику переходов по shortURL за последние days дней
+func (h *URLHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxStatsDays {
+			h.sendError(w, "invalid days parameter", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	// считаем с начала суток, чтобы первый день в ряду был полным
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+
+	shortCode := r.PathValue("code")
+	stats, err := h.service.Stats(r.Context(), shortCode, since)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			h.sendError(w, "short URL not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrNoAnalytics):
+			h.sendError(w, "click tracking is disabled", http.StatusNotImplemented)
+		default:
+			h.sendError(w, "failed to get stats", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := StatsResponse{
+		ShortCode:      shortCode,
+		Since:          since,
+		TotalClicks:    stats.Total,
+		UniqueVisitors: stats.UniqueVisitors,
+		Daily:          statsBuckets(stats.Daily),
+		Hourly:         statsBuckets(stats.Hourly),
+	}
+	h.sendJSON(w, resp, http.StatusOK)
+}
+
+// ListLinks отдает страницу ссылок с фильтрами q и host
+func (h *URLHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			h.sendError(w, "invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var cursor *repository.Cursor
+	if value := query.Get("cursor"); value != "" {
+		c, err := repository.DecodeCursor(value)
+		if err != nil {
+			h.sendError(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = c
+	}
+
+	filter := repository.ListFilter{
+		Query: query.Get("q"),
+		Host:  query.Get("host"),
+	}
+
+	page, err := h.service.List(r.Context(), filter, cursor, limit)
+	if err != nil {
+		h.sendError(w, "failed to list short URLs", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ListResponse{
+		Links: make([]LinkResponse, 0, len(page.Links)),
+	}
+	for _, link := range page.Links {
+		resp.Links = append(resp.Links, h.linkResponse(link))
+	}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+	h.sendJSON(w, resp, http.StatusOK)
+}
+
+// DeleteLink удаляет shortURL
+func (h *URLHandler) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	err := h.service.Delete(r.Context(), r.PathValue("code"))
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			h.sendError(w, "short URL not found", http.StatusNotFound)
 			return
 		}
-		h.sendError(w, "failed to resolve short URL", http.StatusInternalServerError)
+		h.sendError(w, "failed to delete short URL", http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, originalURL, http.StatusFound)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateLink перенаправляет shortURL на новый URL
+func (h *URLHandler) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	var req UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		h.sendError(w, "url is required", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.service.Update(r.Context(), r.PathValue("code"), req.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidURL):
+			h.sendError(w, "invalid URL format", http.StatusBadRequest)
+		case errors.Is(err, repository.ErrNotFound):
+			h.sendError(w, "short URL not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrURLShortened):
+			h.sendError(w, "URL already has a short code", http.StatusConflict)
+		case errors.Is(err, service.ErrForbiddenURL):
+			h.sendForbidden(w, err)
+		case errors.Is(err, service.ErrSelfReference):
+			h.sendError(w, "URL points to this shortener", http.StatusUnprocessableEntity)
+		case errors.Is(err, service.ErrRedirectLoop):
+			h.sendError(w, "URL redirects in a loop", http.StatusUnprocessableEntity)
+		default:
+			h.sendError(w, "failed to update short URL", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.sendJSON(w, h.linkResponse(link), http.StatusOK)
+}
+
+func (h *URLHandler) linkResponse(link *repository.Link) LinkResponse {
+	return LinkResponse{
+		ShortCode: link.ShortCode,
+		ShortURL:  h.baseURL + "/" + link.ShortCode,
+		URL:       link.OriginalURL,
+		CreatedAt: link.CreatedAt,
+		Owner:     link.Owner,
+		ExpiresAt: link.ExpiresAt,
+
+		RedirectStatus: link.RedirectStatus,
+	}
+}
+
+func statsBuckets(buckets []repository.ClickBucket) []StatsBucket {
+	result := make([]StatsBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, StatsBucket{Time: bucket.Start, Clicks: bucket.Clicks})
+	}
+	return result
 }
 
 func (h *URLHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	sendJSON(w, data, statusCode)
+}
+
+func sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
@@ -110,11 +416,131 @@ func (h *URLHandler) sendError(w http.ResponseWriter, message string, statusCode
 	h.sendJSON(w, ErrorResponse{Error: message}, statusCode)
 }
 
-func SetupRoutes(handler *URLHandler) http.Handler {
+// createError ответ на ошибку создания ссылки, общий для одиночного
+// и пакетного сокращения
+func createError(err error) (ErrorResponse, int) {
+	switch {
+	case errors.Is(err, service.ErrInvalidURL):
+		return ErrorResponse{Error: "invalid URL format"}, http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidTTL):
+		return ErrorResponse{Error: "invalid expiration"}, http.StatusBadRequest
+	case errors.Is(err, service.ErrRedirectCode):
+		return ErrorResponse{Error: "redirect_status must be 301, 302, 307 or 308"}, http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidAlias):
+		return ErrorResponse{Error: "invalid alias"}, http.StatusBadRequest
+	case errors.Is(err, service.ErrAliasBlocked):
+		return ErrorResponse{Error: "alias contains a blocked word"}, http.StatusBadRequest
+	case errors.Is(err, service.ErrAliasTaken):
+		return ErrorResponse{Error: "alias is already taken"}, http.StatusConflict
+	case errors.Is(err, service.ErrOptionsConflict):
+		return ErrorResponse{Error: "URL already has a short code with different options"}, http.StatusConflict
+	case errors.Is(err, service.ErrURLShortened):
+		return ErrorResponse{Error: "URL already has a short code"}, http.StatusConflict
+	case errors.Is(err, service.ErrForbiddenURL):
+		return forbiddenResponse(err)
+	case errors.Is(err, service.ErrSelfReference):
+		return ErrorResponse{Error: "URL points to this shortener"}, http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrRedirectLoop):
+		return ErrorResponse{Error: "URL redirects in a loop"}, http.StatusUnprocessableEntity
+	default:
+		return ErrorResponse{Error: "failed to create short URL"}, http.StatusInternalServerError
+	}
+}
+
+func (h *URLHandler) sendForbidden(w http.ResponseWriter, err error) {
+	resp, status := forbiddenResponse(err)
+	h.sendJSON(w, resp, status)
+}
+
+// forbiddenResponse ответ на адрес, запрещенный политикой: 403 если нельзя
+// сам адрес назначения, 422 если URL записан недопустимо
+func forbiddenResponse(err error) (ErrorResponse, int) {
+	resp := ErrorResponse{Error: "URL is not allowed"}
+	status := http.StatusForbidden
+
+	var violation *policy.Violation
+	if errors.As(err, &violation) {
+		resp.Reason = violation.Reason
+		switch violation.Rule {
+		case policy.RulePort, policy.RuleUserinfo, policy.RuleHost:
+			status = http.StatusUnprocessableEntity
+		}
+	}
+
+	return resp, status
+}
+
+// sendMistyped отвечает на код с опечаткой и предлагает похожие ссылки
+func (h *URLHandler) sendMistyped(w http.ResponseWriter, r *http.Request, shortCode string) {
+	resp := ErrorResponse{Error: "short URL not found"}
+	for _, code := range h.service.Suggest(r.Context(), shortCode) {
+		resp.Suggestions = append(resp.Suggestions, h.baseURL+"/"+code)
+	}
+
+	h.sendJSON(w, resp, http.StatusNotFound)
+}
+
+// RateLimits ограничители частоты запросов по группам роутов,
+// nil в поле отключает ограничение для группы
+type RateLimits struct {
+	Shorten  *ratelimit.Limiter
+	API      *ratelimit.Limiter
+	Redirect *ratelimit.Limiter
+
+	// Batch считает ссылки пакета поштучно, сам запрос идет по Shorten
+	Batch *ratelimit.Limiter
+
+	// IP проверяется до аутентификации, чтобы запросы с неверным ключом
+	// тоже тратили токены
+	IP *ratelimit.Limiter
+}
+
+// SetupRoutes регистрирует роуты. Если authn не nil, управление ссылками
+// требует API ключ, а редиректы и пробы остаются публичными
+func SetupRoutes(handler *URLHandler, health *HealthHandler, authn *auth.Authenticator, limits *RateLimits) http.Handler {
 	mux := http.NewServeMux()
+	if limits == nil {
+		limits = &RateLimits{}
+	}
+
+	mux.HandleFunc("GET /healthz", health.Liveness)
+	mux.HandleFunc("GET /readyz", health.Readiness)
+
+	// до аутентификации лимит по адресу, после нее по ключу
+	protect := func(limiter *ratelimit.Limiter, h http.HandlerFunc) http.Handler {
+		limited := limit(limiter, h)
+		if authn == nil {
+			return limited
+		}
+		return limit(limits.IP, authn.Middleware(limited))
+	}
+
+	mux.Handle("/shorten", protect(limits.Shorten, handler.Shorten))
+	mux.Handle("POST /api/shorten/batch", protect(limits.Shorten, handler.ShortenBatch(limits.Batch)))
+	mux.Handle("GET /api/links", protect(limits.API, handler.ListLinks))
+	mux.Handle("DELETE /api/links/{code}", protect(limits.API, handler.DeleteLink))
+	mux.Handle("PATCH /api/links/{code}", protect(limits.API, handler.UpdateLink))
+	mux.Handle("GET /api/links/{code}/stats", protect(limits.API, handler.Stats))
 
-	mux.HandleFunc("/shorten", handler.Shorten)
-	mux.HandleFunc("/", handler.Redirect)
+	if authn != nil {
+		keys := NewKeyHandler(authn)
+		admin := func(h http.HandlerFunc) http.Handler {
+			return limit(limits.IP, authn.Middleware(auth.RequireAdmin(h)))
+		}
+
+		mux.Handle("POST /api/admin/keys", admin(keys.CreateKey))
+		mux.Handle("GET /api/admin/keys", admin(keys.ListKeys))
+		mux.Handle("DELETE /api/admin/keys/{id}", admin(keys.RevokeKey))
+	}
+
+	mux.Handle("/", limit(limits.Redirect, http.HandlerFunc(handler.Redirect)))
 
 	return mux
 }
+
+func limit(limiter *ratelimit.Limiter, h http.Handler) http.Handler {
+	if limiter == nil {
+		return h
+	}
+	return limiter.Middleware(h)
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck проверка одной зависимости сервиса
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// HealthHandler отдает liveness и readiness пробы
+type HealthHandler struct {
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{}
+}
+
+// AddCheck добавляет проверку в readiness, вызывается до старта сервера
+func (h *HealthHandler) AddCheck(name string, check HealthCheck) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// Drain переводит readiness в failing, чтобы балансировщик снял трафик до остановки сервера
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Liveness отвечает, пока процесс жив, зависимости не проверяет,
+// чтобы недоступная база не приводила к перезапуску
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	sendHealth(w, HealthResponse{Status: "ok"}, http.StatusOK)
+}
+
+// Readiness параллельно прогоняет проверки и отдает 503, если хоть одна упала
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		sendHealth(w, HealthResponse{
+			Status: "fail",
+			Checks: map[string]CheckResult{
+				"shutdown": {Status: "fail", Error: "server is shutting down"},
+			},
+		}, http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, c.check)
+	}
+	wg.Wait()
+
+	resp := HealthResponse{Status: "ok", Checks: make(map[string]CheckResult, len(h.checks))}
+	statusCode := http.StatusOK
+	for i, c := range h.checks {
+		resp.Checks[c.name] = results[i]
+		if results[i].Status != "ok" {
+			resp.Status = "fail"
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+
+	sendHealth(w, resp, statusCode)
+}
+
+func runCheck(ctx context.Context, check HealthCheck) CheckResult {
+	start := time.Now()
+	err := check(ctx)
+
+	result := CheckResult{Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func sendHealth(w http.ResponseWriter, resp HealthResponse, statusCode int) {
+	// пробы не должны оседать в кэшах прокси
+	w.Header().Set("Cache-Control", "no-store")
+	sendJSON(w, resp, statusCode)
+}
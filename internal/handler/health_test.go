@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shortURL/internal/repository/memory"
+	"shortURL/internal/service"
+)
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	health := NewHealthHandler()
+	health.AddCheck("repository", memory.NewMemoryRepository().Ping)
+
+	failing := errors.New("connection refused")
+	var brokenErr error
+	health.AddCheck("broken", func(ctx context.Context) error { return brokenErr })
+
+	readiness := func() (int, HealthResponse) {
+		w := httptest.NewRecorder()
+		health.Readiness(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		var resp HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	code, resp := readiness()
+	if code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("Readiness() = %d %s, want %d ok", code, resp.Status, http.StatusOK)
+	}
+	if len(resp.Checks) != 2 {
+		t.Errorf("Readiness() checks = %v, want 2", resp.Checks)
+	}
+
+	brokenErr = failing
+	code, resp = readiness()
+	if code != http.StatusServiceUnavailable || resp.Status != "fail" {
+		t.Errorf("Readiness() with failing check = %d %s, want %d fail", code, resp.Status, http.StatusServiceUnavailable)
+	}
+	if resp.Checks["broken"].Error != failing.Error() || resp.Checks["repository"].Status != "ok" {
+		t.Errorf("Readiness() checks = %+v", resp.Checks)
+	}
+
+	brokenErr = nil
+	health.Drain()
+	code, resp = readiness()
+	if code != http.StatusServiceUnavailable || resp.Checks["shutdown"].Status != "fail" {
+		t.Errorf("Readiness() after Drain() = %d %+v, want %d", code, resp.Checks, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHealthHandler_Routes(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	health := NewHealthHandler()
+	health.AddCheck("repository", repo.Ping)
+	health.Drain()
+
+	mux := SetupRoutes(NewURLHandler(service.NewURLService(repo), "http://localhost:8080"), health, nil, nil)
+
+	// liveness не зависит от остановки и не уходит в Redirect
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("/healthz status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"shortURL/internal/auth"
+	"shortURL/internal/repository"
+)
+
+// KeyHandler выпуск и отзыв API ключей, доступен только администратору
+type KeyHandler struct {
+	auth *auth.Authenticator
+}
+
+func NewKeyHandler(authn *auth.Authenticator) *KeyHandler {
+	return &KeyHandler{auth: authn}
+}
+
+type CreateKeyRequest struct {
+	Name  string `json:"name"`
+	Admin bool   `json:"admin,omitempty"`
+}
+
+type KeyResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Admin     bool       `json:"admin"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreateKeyResponse struct {
+	KeyResponse
+
+	// Key открытый ключ, показывается один раз
+	Key string `json:"key"`
+}
+
+type KeyListResponse struct {
+	Keys []KeyResponse `json:"keys"`
+}
+
+// CreateKey выпускает новый ключ
+func (h *KeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, ErrorResponse{Error: "invalid request body"}, http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		sendJSON(w, ErrorResponse{Error: "name is required"}, http.StatusBadRequest)
+		return
+	}
+
+	token, key, err := h.auth.CreateKey(r.Context(), req.Name, req.Admin)
+	if err != nil {
+		sendJSON(w, ErrorResponse{Error: "failed to create API key"}, http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, CreateKeyResponse{KeyResponse: keyResponse(key), Key: token}, http.StatusCreated)
+}
+
+// ListKeys отдает все ключи без секретов
+func (h *KeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.auth.ListKeys(r.Context())
+	if err != nil {
+		sendJSON(w, ErrorResponse{Error: "failed to list API keys"}, http.StatusInternalServerError)
+		return
+	}
+
+	resp := KeyListResponse{Keys: make([]KeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, keyResponse(key))
+	}
+	sendJSON(w, resp, http.StatusOK)
+}
+
+// RevokeKey отзывает ключ
+func (h *KeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	err := h.auth.RevokeKey(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			sendJSON(w, ErrorResponse{Error: "API key not found"}, http.StatusNotFound)
+			return
+		}
+		sendJSON(w, ErrorResponse{Error: "failed to revoke API key"}, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func keyResponse(key *repository.APIKey) KeyResponse {
+	return KeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Admin:     key.Admin,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
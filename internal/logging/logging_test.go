@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "warn")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	logger.Info("skipped")
+	logger.WarnContext(WithRequestID(context.Background(), "req-1"), "kept", "code", "abc")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not a single JSON line: %q", buf.String())
+	}
+	if entry["msg"] != "kept" || entry["request_id"] != "req-1" || entry["code"] != "abc" {
+		t.Errorf("entry = %v", entry)
+	}
+
+	if _, err := New(io.Discard, "xml", "info"); err == nil {
+		t.Error("New() with invalid format succeeded")
+	}
+	if _, err := New(io.Discard, FormatText, "loud"); err == nil {
+		t.Error("New() with invalid level succeeded")
+	}
+}
+
+func TestRequestLogger_Middleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, FormatJSON, "info")
+
+	var seen string
+	handler := NewRequestLogger(logger).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"propagated", "abc-123", true},
+		{"invalid replaced", "bad\nid", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest("POST", "/shorten", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || id != seen {
+				t.Fatalf("response id %q, context id %q", id, seen)
+			}
+			if tt.keep != (id == tt.incoming) {
+				t.Errorf("request id = %q, incoming %q", id, tt.incoming)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("failed to decode log line %q: %v", buf.String(), err)
+			}
+			if entry["request_id"] != id || entry["method"] != "POST" || entry["path"] != "/shorten" {
+				t.Errorf("entry = %v", entry)
+			}
+			if entry["status"] != float64(http.StatusCreated) || entry["bytes"] != float64(5) {
+				t.Errorf("status/bytes = %v/%v, want 201/5", entry["status"], entry["bytes"])
+			}
+		})
+	}
+}
+
+func TestContextHandler_WithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, FormatText, "debug")
+
+	logger.With("component", "sweeper").DebugContext(WithRequestID(context.Background(), "r1"), "tick")
+
+	out := buf.String()
+	if !strings.Contains(out, "component=sweeper") || !strings.Contains(out, "request_id=r1") {
+		t.Errorf("output = %q", out)
+	}
+	if !strings.Contains(out, "level=DEBUG") {
+		t.Errorf("output = %q, want debug level", out)
+	}
+}
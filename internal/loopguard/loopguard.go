@@ -0,0 +1,243 @@
+// Package loopguard не дает сокращать ссылки на самих себя и строить
+// петли редиректов через другие сокращатели
+package loopguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"shortURL/pkg/urlnorm"
+)
+
+var (
+	ErrSelfReference = errors.New("URL points to this shortener")
+	ErrRedirectLoop  = errors.New("URL redirects in a loop")
+)
+
+// Что делать со ссылкой на наш же домен
+const (
+	// ModeReject отклоняет такие ссылки
+	ModeReject = "reject"
+	// ModeResolve подменяет ссылку ее конечным адресом
+	ModeResolve = "resolve"
+)
+
+// DefaultShortenerHosts известные сокращатели, за которыми можно идти
+var DefaultShortenerHosts = []string{
+	"bit.ly", "bitly.com", "tinyurl.com", "t.co", "goo.gl", "ow.ly",
+	"is.gd", "buff.ly", "rebrand.ly", "cutt.ly", "t.ly", "rb.gy", "shorturl.at",
+}
+
+// Doer отправляет HTTP запрос, в тестах подменяется заглушкой
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// LookupFunc ищет адрес нашей ссылки по коду, found false если ее нет
+type LookupFunc func(ctx context.Context, code string) (target string, found bool, err error)
+
+type Config struct {
+	// наши домены, с которых отдаются короткие ссылки
+	SelfHosts []string
+	// путь из BASE_URL, под которым лежат коды
+	BasePath string
+	// ModeReject или ModeResolve
+	Mode string
+
+	// идти по редиректам сокращателей из ShortenerHosts
+	FollowShorteners bool
+	ShortenerHosts   []string
+
+	// сколько редиректов проходим, прежде чем считать цепочку петлей
+	MaxHops int
+}
+
+type Guard struct {
+	cfg        Config
+	client     Doer
+	selfHosts  map[string]bool
+	shorteners map[string]bool
+}
+
+// NewClient клиент по умолчанию: не ходит по редиректам сам, чтобы Guard
+// видел каждый шаг
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func New(cfg Config, client Doer) (*Guard, error) {
+	if cfg.Mode != ModeReject && cfg.Mode != ModeResolve {
+		return nil, fmt.Errorf("invalid self reference mode: %s", cfg.Mode)
+	}
+	if cfg.MaxHops < 1 {
+		return nil, errors.New("max hops must be positive")
+	}
+
+	g := &Guard{
+		cfg:        cfg,
+		client:     client,
+		selfHosts:  make(map[string]bool),
+		shorteners: make(map[string]bool),
+	}
+	g.cfg.BasePath = strings.TrimSuffix(cfg.BasePath, "/")
+
+	for _, host := range cfg.SelfHosts {
+		normalized, err := hostname(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid self host %q", host)
+		}
+		g.selfHosts[normalized] = true
+	}
+	for _, host := range cfg.ShortenerHosts {
+		normalized, err := hostname(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shortener host %q", host)
+		}
+		g.shorteners[normalized] = true
+	}
+
+	return g, nil
+}
+
+// Check возвращает адрес, который нужно сохранить. Ссылка на наш домен
+// отклоняется или подменяется конечным адресом в зависимости от режима,
+// затем, если включено, проходим по цепочке сокращателей в поисках петли
+func (g *Guard) Check(ctx context.Context, rawURL string, lookup LookupFunc) (string, error) {
+	target := rawURL
+	visited := make(map[string]bool)
+
+	for hops := 0; g.isSelf(target); hops++ {
+		if g.cfg.Mode == ModeReject {
+			return "", ErrSelfReference
+		}
+		if hops >= g.cfg.MaxHops || visited[target] {
+			return "", ErrRedirectLoop
+		}
+		visited[target] = true
+
+		next, found, err := g.lookupSelf(ctx, target, lookup)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("%w: short link does not exist", ErrSelfReference)
+		}
+		target = next
+	}
+
+	if g.cfg.FollowShorteners {
+		if err := g.walk(ctx, target, visited, lookup); err != nil {
+			return "", err
+		}
+	}
+
+	return target, nil
+}
+
+// walk идет по редиректам сокращателей и нашим ссылкам. Цепочка, которая
+// обрывается или уходит на обычный сайт, петлей не считается
+func (g *Guard) walk(ctx context.Context, current string, visited map[string]bool, lookup LookupFunc) error {
+	for hops := 0; hops < g.cfg.MaxHops; hops++ {
+		if visited[current] {
+			return ErrRedirectLoop
+		}
+		visited[current] = true
+
+		var next string
+		var found bool
+		var err error
+		switch {
+		case g.isSelf(current):
+			next, found, err = g.lookupSelf(ctx, current, lookup)
+			if err != nil {
+				return err
+			}
+		case g.isShortener(current):
+			// сокращатель недоступен: петлю не доказать, ссылку не блокируем
+			next, found = g.follow(ctx, current)
+		default:
+			return nil
+		}
+		if !found {
+			return nil
+		}
+		current = next
+	}
+
+	// цепочка все еще не кончилась
+	if g.isSelf(current) || g.isShortener(current) {
+		return ErrRedirectLoop
+	}
+	return nil
+}
+
+// lookupSelf ищет нашу ссылку по коду из пути
+func (g *Guard) lookupSelf(ctx context.Context, rawURL string, lookup LookupFunc) (string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false, nil
+	}
+
+	code, ok := strings.CutPrefix(u.Path, g.cfg.BasePath+"/")
+	if !ok || code == "" || strings.Contains(code, "/") {
+		return "", false, nil
+	}
+
+	return lookup(ctx, code)
+}
+
+// follow делает HEAD запрос и возвращает адрес из Location
+func (g *Guard) follow(ctx context.Context, rawURL string) (string, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return "", false
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return "", false
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return "", false
+	}
+	return location.String(), true
+}
+
+func (g *Guard) isSelf(rawURL string) bool {
+	return g.hostIn(rawURL, g.selfHosts)
+}
+
+func (g *Guard) isShortener(rawURL string) bool {
+	return g.hostIn(rawURL, g.shorteners)
+}
+
+func (g *Guard) hostIn(rawURL string, hosts map[string]bool) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host, err := hostname(u.Hostname())
+	return err == nil && hosts[host]
+}
+
+// hostname сравнимый вид хоста: нижний регистр, punycode, без точки в конце
+func hostname(host string) (string, error) {
+	return urlnorm.Host(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,173 @@
+package loopguard
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// stubClient отвечает редиректами из карты, без сети
+type stubClient struct {
+	redirects map[string]string
+	calls     int
+}
+
+func (c *stubClient) Do(req *http.Request) (*http.Response, error) {
+	c.calls++
+	if req.Method != http.MethodHead {
+		return nil, errors.New("unexpected method " + req.Method)
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}
+	if location, ok := c.redirects[req.URL.String()]; ok {
+		resp.StatusCode = http.StatusMovedPermanently
+		resp.Header.Set("Location", location)
+	}
+	return resp, nil
+}
+
+func lookupIn(links map[string]string) LookupFunc {
+	return func(ctx context.Context, code string) (string, bool, error) {
+		target, ok := links[code]
+		return target, ok, nil
+	}
+}
+
+func newGuard(t *testing.T, cfg Config, client Doer) *Guard {
+	t.Helper()
+
+	if cfg.Mode == "" {
+		cfg.Mode = ModeResolve
+	}
+	if cfg.MaxHops == 0 {
+		cfg.MaxHops = 5
+	}
+	cfg.SelfHosts = append(cfg.SelfHosts, "sho.rt")
+
+	g, err := New(cfg, client)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return g
+}
+
+func TestGuard_SelfReference(t *testing.T) {
+	links := lookupIn(map[string]string{
+		"abc":   "https://example.com/a",
+		"inner": "https://sho.rt/abc",
+		"self":  "https://sho.rt/self",
+	})
+	ctx := context.Background()
+
+	resolve := newGuard(t, Config{SelfHosts: []string{"Go.Example.ORG"}}, nil)
+	tests := []struct {
+		url    string
+		target string
+		err    error
+	}{
+		{"https://example.com/", "https://example.com/", nil},
+		{"https://sho.rt/abc", "https://example.com/a", nil},
+		{"http://SHO.RT./abc?x=1", "https://example.com/a", nil},
+		{"https://go.example.org/abc", "https://example.com/a", nil},
+		{"https://sho.rt/inner", "https://example.com/a", nil},
+		{"https://sho.rt/missing", "", ErrSelfReference},
+		{"https://sho.rt/", "", ErrSelfReference},
+		{"https://sho.rt/api/links", "", ErrSelfReference},
+		{"https://sho.rt/self", "", ErrRedirectLoop},
+	}
+	for _, tt := range tests {
+		target, err := resolve.Check(ctx, tt.url, links)
+		if !errors.Is(err, tt.err) || target != tt.target {
+			t.Errorf("Check(%q) = %q, %v, want %q, %v", tt.url, target, err, tt.target, tt.err)
+		}
+	}
+
+	reject := newGuard(t, Config{Mode: ModeReject}, nil)
+	if _, err := reject.Check(ctx, "https://sho.rt/abc", links); !errors.Is(err, ErrSelfReference) {
+		t.Errorf("Check() in reject mode = %v, want %v", err, ErrSelfReference)
+	}
+}
+
+func TestGuard_BasePath(t *testing.T) {
+	g := newGuard(t, Config{BasePath: "/s/"}, nil)
+	links := lookupIn(map[string]string{"abc": "https://example.com/"})
+
+	if target, err := g.Check(context.Background(), "https://sho.rt/s/abc", links); err != nil || target != "https://example.com/" {
+		t.Errorf("Check() = %q, %v", target, err)
+	}
+	if _, err := g.Check(context.Background(), "https://sho.rt/abc", links); !errors.Is(err, ErrSelfReference) {
+		t.Errorf("Check() outside base path = %v, want %v", err, ErrSelfReference)
+	}
+}
+
+func TestGuard_FollowShorteners(t *testing.T) {
+	client := &stubClient{redirects: map[string]string{
+		"https://bit.ly/loop":      "https://sho.rt/back",
+		"https://bit.ly/a":         "https://tinyurl.com/b",
+		"https://tinyurl.com/b":    "https://example.com/",
+		"https://bit.ly/ping":      "https://tinyurl.com/pong",
+		"https://tinyurl.com/pong": "https://bit.ly/ping",
+		"https://bit.ly/rel":       "/other",
+		"https://bit.ly/other":     "https://example.com/",
+	}}
+	links := lookupIn(map[string]string{"back": "https://bit.ly/loop"})
+	ctx := context.Background()
+
+	g := newGuard(t, Config{FollowShorteners: true, ShortenerHosts: []string{"bit.ly", "tinyurl.com"}}, client)
+
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"https://bit.ly/a", nil},
+		{"https://bit.ly/rel", nil},
+		{"https://bit.ly/unknown", nil},
+		{"https://example.com/", nil},
+		{"https://bit.ly/loop", ErrRedirectLoop},
+		{"https://bit.ly/ping", ErrRedirectLoop},
+		// наша ссылка ведет на bit.ly, который ведет обратно на нее
+		{"https://sho.rt/back", ErrRedirectLoop},
+	}
+	for _, tt := range tests {
+		target, err := g.Check(ctx, tt.url, links)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Check(%q) = %v, want %v", tt.url, err, tt.err)
+		}
+		// за сокращателями идем только для проверки, сохраняется исходный адрес
+		if err == nil && strings.HasPrefix(tt.url, "https://bit.ly/") && target != tt.url {
+			t.Errorf("Check(%q) = %q, want unchanged", tt.url, target)
+		}
+	}
+
+	// длинная цепочка без повторов упирается в MaxHops
+	long := &stubClient{redirects: map[string]string{}}
+	for i := 0; i < 10; i++ {
+		long.redirects["https://bit.ly/"+string(rune('a'+i))] = "https://bit.ly/" + string(rune('a'+i+1))
+	}
+	g = newGuard(t, Config{FollowShorteners: true, ShortenerHosts: []string{"bit.ly"}, MaxHops: 3}, long)
+	if _, err := g.Check(ctx, "https://bit.ly/a", links); !errors.Is(err, ErrRedirectLoop) {
+		t.Errorf("Check() of long chain = %v, want %v", err, ErrRedirectLoop)
+	}
+	if long.calls != 3 {
+		t.Errorf("made %d requests, want 3", long.calls)
+	}
+
+	// без FollowShorteners сеть не трогаем
+	client.calls = 0
+	g = newGuard(t, Config{ShortenerHosts: []string{"bit.ly"}}, client)
+	if _, err := g.Check(ctx, "https://bit.ly/loop", links); err != nil || client.calls != 0 {
+		t.Errorf("Check() = %v with %d requests, want no requests", err, client.calls)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	if _, err := New(Config{Mode: "ignore", MaxHops: 1}, nil); err == nil {
+		t.Error("New() with invalid mode succeeded")
+	}
+	if _, err := New(Config{Mode: ModeReject}, nil); err == nil {
+		t.Error("New() without max hops succeeded")
+	}
+}
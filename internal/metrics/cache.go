@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+
+	"shortURL/internal/repository/cache"
+)
+
+// NewCacheCollector отдает счетчики кэша ссылок
+func NewCacheCollector(c *cache.CachedRepository) Collector {
+	return cacheCollector{c}
+}
+
+type cacheCollector struct {
+	cache *cache.CachedRepository
+}
+
+func (c cacheCollector) collect(b *bytes.Buffer) {
+	stats := c.cache.Stats()
+
+	writeHeader(b, "shorturl_cache_hits_total", "Number of link cache hits.", "counter")
+	writeSample(b, "shorturl_cache_hits_total", nil, nil, float64(stats.Hits))
+	writeHeader(b, "shorturl_cache_misses_total", "Number of link cache misses.", "counter")
+	writeSample(b, "shorturl_cache_misses_total", nil, nil, float64(stats.Misses))
+	writeHeader(b, "shorturl_cache_evictions_total", "Number of links evicted from the cache.", "counter")
+	writeSample(b, "shorturl_cache_evictions_total", nil, nil, float64(stats.Evictions))
+	writeHeader(b, "shorturl_cache_entries", "Number of entries in the link cache.", "gauge")
+	writeSample(b, "shorturl_cache_entries", nil, nil, float64(stats.Entries))
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPMetrics считает запросы и их длительность по роутам и статусам
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+func NewHTTPMetrics(reg *Registry) *HTTPMetrics {
+	m := &HTTPMetrics{
+		requests: NewCounterVec("shorturl_http_requests_total",
+			"Number of HTTP requests by route and status.", "route", "status"),
+		duration: NewHistogramVec("shorturl_http_request_duration_seconds",
+			"HTTP request latency by route and status.", DefaultBuckets, "route", "status"),
+	}
+	reg.Register(m.requests)
+	reg.Register(m.duration)
+
+	return m
+}
+
+// Middleware оборачивает мукс, роут берется из паттерна, который выбрал мукс,
+// чтобы коды ссылок не раздували число меток
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rec.status)
+
+		m.requests.Inc(route, status)
+		m.duration.Observe(time.Since(start).Seconds(), route, status)
+	})
+}
+
+// statusRecorder запоминает статус ответа
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap нужен http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMetrics_Middleware(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /shorten", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	handler := m.Middleware(mux)
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/shorten"},
+		{"GET", "/abc123XYZ_"},
+		{"GET", "/def456UVW_"},
+	}
+	for _, req := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	// разные коды попадают в одну серию роута
+	assertContains(t, scrape(t, reg),
+		`shorturl_http_requests_total{route="POST /shorten",status="201"} 1`,
+		`shorturl_http_requests_total{route="/",status="404"} 2`,
+		`shorturl_http_request_duration_seconds_count{route="/",status="404"} 2`,
+	)
+}
@@ -0,0 +1,266 @@
+// Package metrics отдает метрики сервиса в текстовом формате Prometheus
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets границы гистограмм задержек в секундах
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector пишет свои метрики в формате экспозиции
+type Collector interface {
+	collect(b *bytes.Buffer)
+}
+
+// Registry хранит коллекторы и отдает их по HTTP
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// Handler отдает все метрики реестра
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var b bytes.Buffer
+
+		r.mu.RLock()
+		for _, c := range r.collectors {
+			c.collect(&b)
+		}
+		r.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(b.Bytes())
+	})
+}
+
+// CounterVec счетчик с метками
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels []string
+	value  float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*counterSeries),
+	}
+}
+
+// Inc увеличивает счетчик для значений меток в порядке их объявления
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := seriesKey(labelValues)
+	s, exists := c.values[key]
+	if !exists {
+		s = &counterSeries{labels: append([]string(nil), labelValues...)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+func (c *CounterVec) collect(b *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(b, c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		writeSample(b, c.name, c.labels, s.labels, s.value)
+	}
+}
+
+// HistogramVec гистограмма с метками
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	// counts[i] число наблюдений, попавших в buckets[i] и не попавших в меньшие
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogramSeries),
+	}
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := seriesKey(labelValues)
+	s, exists := h.values[key]
+	if !exists {
+		s = &histogramSeries{
+			labels: append([]string(nil), labelValues...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = s
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) collect(b *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(b, h.name, h.help, "histogram")
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+
+		// последнее значение это граница le
+		bucketValues := append(append([]string(nil), s.labels...), "")
+		le := len(bucketValues) - 1
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			bucketValues[le] = formatFloat(upper)
+			writeSample(b, h.name+"_bucket", bucketLabels, bucketValues, float64(cumulative))
+		}
+		bucketValues[le] = "+Inf"
+		writeSample(b, h.name+"_bucket", bucketLabels, bucketValues, float64(s.count))
+		writeSample(b, h.name+"_sum", h.labels, s.labels, s.sum)
+		writeSample(b, h.name+"_count", h.labels, s.labels, float64(s.count))
+	}
+}
+
+// funcMetric значение без меток, которое читается при каждом сборе
+type funcMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func() float64
+}
+
+// NewGaugeFunc гейдж, значение которого берется из функции
+func NewGaugeFunc(name, help string, value func() float64) Collector {
+	return &funcMetric{name: name, help: help, kind: "gauge", value: value}
+}
+
+// NewCounterFunc счетчик, значение которого берется из функции
+func NewCounterFunc(name, help string, value func() float64) Collector {
+	return &funcMetric{name: name, help: help, kind: "counter", value: value}
+}
+
+func (m *funcMetric) collect(b *bytes.Buffer) {
+	writeHeader(b, m.name, m.help, m.kind)
+	writeSample(b, m.name, nil, nil, m.value())
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func writeHeader(b *bytes.Buffer, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, helpEscaper.Replace(help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+}
+
+func writeSample(b *bytes.Buffer, name string, labels, values []string, v float64) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, label, labelEscaper.Replace(labelValue(values, i)))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+// labelValue не дает упасть, если значений меток передали меньше, чем объявлено
+func labelValue(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
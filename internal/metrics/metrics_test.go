@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %s", ct)
+	}
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func assertContains(t *testing.T, body string, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics output missing %q\n%s", line, body)
+		}
+	}
+}
+
+func TestCounterVec(t *testing.T) {
+	reg := NewRegistry()
+	c := NewCounterVec("test_total", "Test counter.", "route", "status")
+	reg.Register(c)
+
+	c.Inc("/b", "200")
+	c.Inc("/a", "404")
+	c.Add(2, "/a", "404")
+	c.Inc(`say "hi"`, "200")
+
+	body := scrape(t, reg)
+	assertContains(t, body,
+		"# HELP test_total Test counter.",
+		"# TYPE test_total counter",
+		`test_total{route="/a",status="404"} 3`,
+		`test_total{route="/b",status="200"} 1`,
+		`test_total{route="say \"hi\"",status="200"} 1`,
+	)
+
+	// серии отсортированы, вывод стабилен между сборами
+	if strings.Index(body, `route="/a"`) > strings.Index(body, `route="/b"`) {
+		t.Errorf("series are not sorted:\n%s", body)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	reg := NewRegistry()
+	h := NewHistogramVec("test_seconds", "Test histogram.", []float64{0.1, 1}, "op")
+	reg.Register(h)
+
+	h.Observe(0.05, "get")
+	h.Observe(0.1, "get")
+	h.Observe(0.5, "get")
+	h.Observe(3, "get")
+
+	assertContains(t, scrape(t, reg),
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{op="get",le="0.1"} 2`,
+		`test_seconds_bucket{op="get",le="1"} 3`,
+		`test_seconds_bucket{op="get",le="+Inf"} 4`,
+		`test_seconds_sum{op="get"} 3.65`,
+		`test_seconds_count{op="get"} 4`,
+	)
+}
+
+func TestFuncMetrics(t *testing.T) {
+	reg := NewRegistry()
+	value := 1.0
+	reg.Register(NewGaugeFunc("test_gauge", "Test gauge.", func() float64 { return value }))
+	reg.Register(NewRuntimeCollector())
+
+	assertContains(t, scrape(t, reg), "# TYPE test_gauge gauge", "test_gauge 1")
+
+	value = 2
+	body := scrape(t, reg)
+	assertContains(t, body, "test_gauge 2", "# TYPE go_goroutines gauge", "# TYPE go_gc_cycles_total counter")
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"shortURL/internal/repository"
+)
+
+// RepositoryMetrics длительность и ошибки операций хранилища
+type RepositoryMetrics struct {
+	duration *HistogramVec
+	errors   *CounterVec
+}
+
+func NewRepositoryMetrics(reg *Registry) *RepositoryMetrics {
+	m := &RepositoryMetrics{
+		duration: NewHistogramVec("shorturl_repository_operation_duration_seconds",
+			"Repository operation latency by backend and operation.", DefaultBuckets, "backend", "operation"),
+		errors: NewCounterVec("shorturl_repository_errors_total",
+			"Number of failed repository operations by backend and operation.", "backend", "operation"),
+	}
+	reg.Register(m.duration)
+	reg.Register(m.errors)
+
+	return m
+}
+
+func (m *RepositoryMetrics) observe(backend, operation string, start time.Time, err error) {
+	m.duration.Observe(time.Since(start).Seconds(), backend, operation)
+	if isFailure(err) {
+		m.errors.Inc(backend, operation)
+	}
+}
+
+// isFailure отделяет сбои хранилища от ожидаемых ответов вроде "не найдено"
+func isFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, repository.ErrNotFound) &&
+		!errors.Is(err, repository.ErrAlreadyExists) &&
+		!errors.Is(err, repository.ErrDuplicate)
+}
+
+// InstrumentedRepository замеряет каждую операцию обернутого репозитория
+type InstrumentedRepository struct {
+	repo    repository.URLRepository
+	backend string
+	metrics *RepositoryMetrics
+}
+
+func NewInstrumentedRepository(repo repository.URLRepository, backend string, m *RepositoryMetrics) *InstrumentedRepository {
+	return &InstrumentedRepository{repo: repo, backend: backend, metrics: m}
+}
+
+func (r *InstrumentedRepository) Save(ctx context.Context, link *repository.Link) error {
+	start := time.Now()
+	err := r.repo.Save(ctx, link)
+	r.metrics.observe(r.backend, "save", start, err)
+	return err
+}
+
+func (r *InstrumentedRepository) SaveMany(ctx context.Context, links []*repository.Link) ([]error, error) {
+	start := time.Now()
+	errs, err := r.repo.SaveMany(ctx, links)
+	r.metrics.observe(r.backend, "save_many", start, err)
+	return errs, err
+}
+
+func (r *InstrumentedRepository) Get(ctx context.Context, shortCode string) (*repository.Link, error) {
+	start := time.Now()
+	link, err := r.repo.Get(ctx, shortCode)
+	r.metrics.observe(r.backend, "get", start, err)
+	return link, err
+}
+
+func (r *InstrumentedRepository) GetByOriginal(ctx context.Context, owner, originalURL string) (string, error) {
+	start := time.Now()
+	shortCode, err := r.repo.GetByOriginal(ctx, owner, originalURL)
+	r.metrics.observe(r.backend, "get_by_original", start, err)
+	return shortCode, err
+}
+
+func (r *InstrumentedRepository) Delete(ctx context.Context, shortCode string) error {
+	start := time.Now()
+	err := r.repo.Delete(ctx, shortCode)
+	r.metrics.observe(r.backend, "delete", start, err)
+	return err
+}
+
+func (r *InstrumentedRepository) Update(ctx context.Context, shortCode string, newURL string) error {
+	start := time.Now()
+	err := r.repo.Update(ctx, shortCode, newURL)
+	r.metrics.observe(r.backend, "update", start, err)
+	return err
+}
+
+func (r *InstrumentedRepository) List(ctx context.Context, filter repository.ListFilter, cursor *repository.Cursor, limit int) (*repository.LinkPage, error) {
+	start := time.Now()
+	page, err := r.repo.List(ctx, filter, cursor, limit)
+	r.metrics.observe(r.backend, "list", start, err)
+	return page, err
+}
+
+func (r *InstrumentedRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	start := time.Now()
+	deleted, err := r.repo.DeleteExpired(ctx, now)
+	r.metrics.observe(r.backend, "delete_expired", start, err)
+	return deleted, err
+}
+
+func (r *InstrumentedRepository) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := r.repo.Ping(ctx)
+	r.metrics.observe(r.backend, "ping", start, err)
+	return err
+}
+
+func (r *InstrumentedRepository) Close() error {
+	return r.repo.Close()
+}
+
+// InstrumentedClickRepository замеряет операции хранилища переходов
+type InstrumentedClickRepository struct {
+	repo    repository.ClickRepository
+	backend string
+	metrics *RepositoryMetrics
+}
+
+func NewInstrumentedClickRepository(repo repository.ClickRepository, backend string, m *RepositoryMetrics) *InstrumentedClickRepository {
+	return &InstrumentedClickRepository{repo: repo, backend: backend, metrics: m}
+}
+
+func (r *InstrumentedClickRepository) SaveClicks(ctx context.Context, clicks []repository.Click) error {
+	start := time.Now()
+	err := r.repo.SaveClicks(ctx, clicks)
+	r.metrics.observe(r.backend, "save_clicks", start, err)
+	return err
+}
+
+func (r *InstrumentedClickRepository) ClickStats(ctx context.Context, shortCode string, since time.Time) (*repository.ClickStats, error) {
+	start := time.Now()
+	stats, err := r.repo.ClickStats(ctx, shortCode, since)
+	r.metrics.observe(r.backend, "click_stats", start, err)
+	return stats, err
+}
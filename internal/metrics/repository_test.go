@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"shortURL/internal/repository"
+	"shortURL/internal/repository/memory"
+	"shortURL/internal/repository/repotest"
+)
+
+func TestInstrumentedRepository_Conformance(t *testing.T) {
+	m := NewRepositoryMetrics(NewRegistry())
+	repotest.Run(t, func(t *testing.T) repository.URLRepository {
+		return NewInstrumentedRepository(memory.NewMemoryRepository(), "memory", m)
+	})
+}
+
+func TestInstrumentedRepository(t *testing.T) {
+	reg := NewRegistry()
+	repo := NewInstrumentedRepository(memory.NewMemoryRepository(), "memory", NewRepositoryMetrics(reg))
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+	_, _ = repo.Get(ctx, "abc123XYZ_")
+	// ненайденная ссылка не считается ошибкой хранилища
+	_, _ = repo.Get(ctx, "missing___")
+	// а некорректный лимит считается
+	_, _ = repo.List(ctx, repository.ListFilter{}, nil, 0)
+
+	body := scrape(t, reg)
+	assertContains(t, body,
+		`shorturl_repository_operation_duration_seconds_count{backend="memory",operation="save"} 1`,
+		`shorturl_repository_operation_duration_seconds_count{backend="memory",operation="get"} 2`,
+		`shorturl_repository_errors_total{backend="memory",operation="list"} 1`,
+	)
+	if strings.Contains(body, `shorturl_repository_errors_total{backend="memory",operation="get"}`) {
+		t.Errorf("ErrNotFound counted as repository error:\n%s", body)
+	}
+}
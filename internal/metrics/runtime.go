@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"bytes"
+	"runtime"
+)
+
+type runtimeCollector struct{}
+
+// NewRuntimeCollector метрики рантайма Go: горутины, память, сборки мусора
+func NewRuntimeCollector() Collector {
+	return runtimeCollector{}
+}
+
+func (runtimeCollector) collect(b *bytes.Buffer) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	writeHeader(b, "go_info", "Information about the Go environment.", "gauge")
+	writeSample(b, "go_info", []string{"version"}, []string{runtime.Version()}, 1)
+
+	gauges := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(m.Alloc)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", float64(m.HeapObjects)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(m.HeapInuse)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(m.Sys)},
+		{"go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of last garbage collection.", float64(m.LastGC) / 1e9},
+	}
+	for _, g := range gauges {
+		writeHeader(b, g.name, g.help, "gauge")
+		writeSample(b, g.name, nil, nil, g.value)
+	}
+
+	writeHeader(b, "go_gc_cycles_total", "Number of completed GC cycles.", "counter")
+	writeSample(b, "go_gc_cycles_total", nil, nil, float64(m.NumGC))
+	writeHeader(b, "go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses.", "counter")
+	writeSample(b, "go_gc_pause_seconds_total", nil, nil, float64(m.PauseTotalNs)/1e9)
+}
@@ -0,0 +1,165 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"shortURL/pkg/urlnorm"
+)
+
+// domainSet домены из файла: точные и шаблоны *.suffix
+type domainSet struct {
+	exact    map[string]bool
+	wildcard map[string]bool
+}
+
+// match проверяет сам хост, а затем каждый его родительский домен
+// среди шаблонов *.suffix
+func (d *domainSet) match(host string) bool {
+	if d.exact[host] {
+		return true
+	}
+
+	for {
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 {
+			return false
+		}
+		host = host[dot+1:]
+		if d.wildcard[host] {
+			return true
+		}
+	}
+}
+
+// loadDomains читает файл: домен или *.домен в строке, # начинает комментарий
+func loadDomains(path string) (*domainSet, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	set := &domainSet{exact: make(map[string]bool), wildcard: make(map[string]bool)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		wildcard := strings.HasPrefix(entry, "*.")
+		domain, err := urlnorm.Host(strings.TrimSuffix(strings.TrimPrefix(entry, "*."), "."))
+		if err != nil || !validDomain(domain) {
+			return nil, time.Time{}, fmt.Errorf("%s:%d: invalid domain %q", path, line, entry)
+		}
+
+		if wildcard {
+			set.wildcard[domain] = true
+		} else {
+			set.exact[domain] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return set, info.ModTime(), nil
+}
+
+// validDomain после punycode в домене остаются только буквы, цифры, - _ и точки
+func validDomain(domain string) bool {
+	if domain == "" {
+		return false
+	}
+	for i := 0; i < len(domain); i++ {
+		c := domain[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Reload перечитывает оба списка. При ошибке остаются прежние списки
+func (p *Policy) Reload() error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	return p.reload(true)
+}
+
+// reloadChanged перечитывает списки, только если файлы изменились
+func (p *Policy) reloadChanged() error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	return p.reload(false)
+}
+
+// reload вызывается под reloadMu
+func (p *Policy) reload(force bool) error {
+	prev := p.lists.Load()
+	if prev == nil {
+		prev = &lists{}
+	}
+
+	changed := force
+	if !force {
+		for _, f := range []struct {
+			path string
+			mod  time.Time
+		}{{p.cfg.BlocklistPath, prev.blockMod}, {p.cfg.AllowlistPath, prev.allowMod}} {
+			if f.path == "" {
+				continue
+			}
+			info, err := os.Stat(f.path)
+			if err != nil {
+				return err
+			}
+			if !info.ModTime().Equal(f.mod) {
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	next := &lists{}
+	var err error
+	if p.cfg.BlocklistPath != "" {
+		if next.block, next.blockMod, err = loadDomains(p.cfg.BlocklistPath); err != nil {
+			return err
+		}
+	}
+	if p.cfg.AllowlistPath != "" {
+		if next.allow, next.allowMod, err = loadDomains(p.cfg.AllowlistPath); err != nil {
+			return err
+		}
+	}
+
+	p.lists.Store(next)
+	if !force {
+		slog.Info("URL policy reloaded", "blocked", next.block.size(), "allowed", next.allow.size())
+	}
+
+	return nil
+}
+
+func (d *domainSet) size() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.exact) + len(d.wildcard)
+}
@@ -0,0 +1,248 @@
+// Package policy решает, на какие адреса можно делать короткие ссылки
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"shortURL/pkg/urlnorm"
+)
+
+var ErrForbiddenURL = errors.New("forbidden URL")
+
+// Правила, по которым отклоняется адрес
+const (
+	RuleBlocklist = "blocklist"
+	RuleAllowlist = "allowlist"
+	RulePrivateIP = "private_ip"
+	RulePort      = "port"
+	RuleUserinfo  = "userinfo"
+	RuleHost      = "host"
+)
+
+// Violation ошибка с причиной отказа, errors.Is(err, ErrForbiddenURL) true
+type Violation struct {
+	Rule   string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return ErrForbiddenURL.Error() + ": " + v.Reason
+}
+
+func (v *Violation) Is(target error) bool {
+	return target == ErrForbiddenURL
+}
+
+type Config struct {
+	// файлы с доменами, по одному в строке. *.example.com совпадает
+	// с поддоменами, example.com только с самим доменом. Пустой путь
+	// отключает список, непустой allowlist пропускает только свои домены
+	BlocklistPath string
+	AllowlistPath string
+
+	// как часто проверять, не изменились ли файлы, 0 отключает
+	ReloadInterval time.Duration
+
+	// пропускать адреса из приватных, loopback и link-local сетей
+	AllowPrivateIPs bool
+
+	// ResolveHosts проверяет еще и адреса, в которые резолвится хост
+	ResolveHosts bool
+
+	// разрешенные явно указанные порты, пустой список разрешает любые
+	AllowedPorts []int
+
+	// пропускать user:password@ в URL
+	AllowUserinfo bool
+}
+
+// Policy проверяет адреса, списки доменов подменяются атомарно при перечитывании
+type Policy struct {
+	cfg     Config
+	resolve func(ctx context.Context, host string) ([]netip.Addr, error)
+
+	lists atomic.Pointer[lists]
+
+	// reloadMu не дает двум перечитываниям идти одновременно
+	reloadMu sync.Mutex
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type lists struct {
+	block, allow       *domainSet
+	blockMod, allowMod time.Time
+}
+
+// New читает списки доменов, ошибка в файле не дает стартовать
+func New(cfg Config) (*Policy, error) {
+	p := &Policy{
+		cfg: cfg,
+		resolve: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Check возвращает *Violation, если на адрес нельзя делать ссылку
+func (p *Policy) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrForbiddenURL
+	}
+
+	if u.User != nil && !p.cfg.AllowUserinfo {
+		return &Violation{Rule: RuleUserinfo, Reason: "credentials in URL are not allowed"}
+	}
+
+	if port := u.Port(); port != "" && !p.portAllowed(port) {
+		return &Violation{Rule: RulePort, Reason: fmt.Sprintf("port %s is not allowed", port)}
+	}
+
+	host, err := urlnorm.Host(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil {
+		return &Violation{Rule: RuleHost, Reason: "invalid host"}
+	}
+
+	addr, err := netip.ParseAddr(host)
+	isIP := err == nil
+	switch {
+	case isIP:
+		if err := p.checkAddr(addr); err != nil {
+			return err
+		}
+	case !p.cfg.AllowPrivateIPs && numericHost(host):
+		// 2130706433 или 0x7f.1 браузеры понимают как 127.0.0.1
+		return &Violation{Rule: RulePrivateIP, Reason: "numeric host is not allowed"}
+	case !p.cfg.AllowPrivateIPs && (host == "localhost" || strings.HasSuffix(host, ".localhost")):
+		return &Violation{Rule: RulePrivateIP, Reason: "localhost is not allowed"}
+	}
+
+	l := p.lists.Load()
+	if l.allow != nil && !l.allow.match(host) {
+		return &Violation{Rule: RuleAllowlist, Reason: fmt.Sprintf("host %s is not on the allowlist", host)}
+	}
+	if l.block != nil && l.block.match(host) {
+		return &Violation{Rule: RuleBlocklist, Reason: fmt.Sprintf("host %s is blocked", host)}
+	}
+
+	if !isIP && p.cfg.ResolveHosts && !p.cfg.AllowPrivateIPs {
+		addrs, err := p.resolve(ctx, host)
+		if err != nil {
+			return &Violation{Rule: RulePrivateIP, Reason: fmt.Sprintf("host %s does not resolve", host)}
+		}
+		for _, addr := range addrs {
+			if err := p.checkAddr(addr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) checkAddr(addr netip.Addr) error {
+	if p.cfg.AllowPrivateIPs {
+		return nil
+	}
+
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() || cgnat.Contains(addr) {
+		return &Violation{Rule: RulePrivateIP, Reason: fmt.Sprintf("address %s is not public", addr)}
+	}
+
+	return nil
+}
+
+// cgnat 100.64.0.0/10 тоже не маршрутизируется в интернете
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+func (p *Policy) portAllowed(port string) bool {
+	if len(p.cfg.AllowedPorts) == 0 {
+		return true
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range p.cfg.AllowedPorts {
+		if n == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// numericHost последняя метка из цифр или 0x..., а это не домен
+func numericHost(host string) bool {
+	label := host[strings.LastIndex(host, ".")+1:]
+	if label == "" {
+		return false
+	}
+
+	if strings.HasPrefix(label, "0x") {
+		_, err := strconv.ParseUint(label[2:], 16, 64)
+		return err == nil
+	}
+	_, err := strconv.ParseUint(label, 10, 64)
+	return err == nil
+}
+
+// Start запускает перечитывание измененных файлов в отдельной горутине
+func (p *Policy) Start() {
+	if p.cfg.ReloadInterval <= 0 || (p.cfg.BlocklistPath == "" && p.cfg.AllowlistPath == "") {
+		close(p.done)
+		return
+	}
+	go p.run()
+}
+
+// Stop останавливает перечитывание
+func (p *Policy) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
+
+func (p *Policy) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if err := p.reloadChanged(); err != nil {
+				slog.Error("failed to reload URL policy, keeping previous lists", "error", err)
+			}
+		}
+	}
+}
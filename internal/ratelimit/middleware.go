@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"shortURL/internal/auth"
+)
+
+// KeyFunc определяет клиента по запросу
+type KeyFunc func(r *http.Request) string
+
+// Middleware отвечает 429, когда у клиента кончились токены.
+// Заголовки RateLimit-* отдаются на каждый ответ
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := l.Allow(l.key(r))
+
+		SetHeaders(w.Header(), res)
+		if !res.Allowed {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetHeaders выставляет заголовки RateLimit-*, а при отказе и Retry-After
+func SetHeaders(h http.Header, res Result) {
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(seconds(res.RetryAfter), 1)))
+	}
+}
+
+// seconds округляет вверх, чтобы клиент не пришел раньше времени
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ClientIP определяет адрес клиента. X-Forwarded-For учитывается, только
+// если запрос пришел от доверенного прокси
+type ClientIP struct {
+	trusted []netip.Prefix
+}
+
+func NewClientIP(trusted []netip.Prefix) *ClientIP {
+	return &ClientIP{trusted: trusted}
+}
+
+// ParseTrustedProxies разбирает список адресов и подсетей через запятую
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", field, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", field, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Resolve идет по X-Forwarded-For справа налево, пропуская доверенные
+// прокси. Первый недоверенный адрес и есть клиент: левее него значения
+// мог подставить сам клиент
+func (c *ClientIP) Resolve(r *http.Request) string {
+	remote, err := parseAddr(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	if !c.isTrusted(remote) {
+		return remote.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+
+		addr, err := parseAddr(hop)
+		if err != nil {
+			break
+		}
+		client = addr
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// Key ключ клиента: API ключ, если запрос прошел аутентификацию, иначе адрес
+func (c *ClientIP) Key(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + principal.KeyID
+	}
+	return "ip:" + c.Resolve(r)
+}
+
+func (c *ClientIP) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr принимает адрес как с портом, так и без
+func parseAddr(s string) (netip.Addr, error) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
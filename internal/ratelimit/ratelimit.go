@@ -0,0 +1,145 @@
+// Package ratelimit ограничивает частоту запросов одного клиента token bucket'ом
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limit параметры корзины: Rate токенов в секунду, не больше Burst за раз
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Check проверяет, что лимит можно использовать
+func (l Limit) Check() error {
+	if l.Rate <= 0 || math.IsInf(l.Rate, 0) || math.IsNaN(l.Rate) {
+		return errors.New("rate must be positive")
+	}
+	if l.Burst < 1 {
+		return errors.New("burst must be at least 1")
+	}
+	return nil
+}
+
+// fillTime за сколько пустая корзина наполняется целиком
+func (l Limit) fillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result итог проверки запроса
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// через сколько корзина снова будет полной
+	Reset time.Duration
+	// через сколько появится следующий токен, 0 если запрос пропущен
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter хранит по корзине на ключ клиента
+type Limiter struct {
+	limit Limit
+	key   KeyFunc
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	// когда последний раз выкидывали простаивающие корзины
+	lastEvict time.Time
+}
+
+// NewLimiter создает ограничитель, key определяет клиента в Middleware
+func NewLimiter(limit Limit, key KeyFunc) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		key:     key,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow списывает токен с корзины key, если он есть
+func (l *Limiter) Allow(key string) Result {
+	_, res := l.AllowN(key, 1)
+	return res
+}
+
+// AllowN списывает до n токенов с корзины key, сколько есть целых, и
+// возвращает их число. Result.Allowed, только если списаны все n
+func (l *Limiter) AllowN(key string, n int) (int, Result) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.evict(now)
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+
+	granted := min(n, int(b.tokens))
+	b.tokens -= float64(granted)
+
+	res := Result{Limit: l.limit.Burst}
+	if granted == n {
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.wait(1 - b.tokens)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = l.wait(float64(l.limit.Burst) - b.tokens)
+
+	return granted, res
+}
+
+// Key ключ клиента запроса, тот же, что считает Middleware
+func (l *Limiter) Key(r *http.Request) string {
+	return l.key(r)
+}
+
+// wait сколько ждать, пока накопится tokens токенов
+func (l *Limiter) wait(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// evict выкидывает корзины, которые успели наполниться целиком: они ничем
+// не отличаются от новых. Проход делается не чаще раза за время наполнения,
+// поэтому в памяти остаются только клиенты, активные за это время.
+// Вызывается под блокировкой
+func (l *Limiter) evict(now time.Time) {
+	idle := l.limit.fillTime()
+	if now.Sub(l.lastEvict) < idle {
+		return
+	}
+	l.lastEvict = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Len число корзин в памяти
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shortURL/internal/auth"
+)
+
+func newTestLimiter(limit Limit, key KeyFunc) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(limit, key)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 2, Burst: 3}, nil)
+
+	for i := 0; i < 3; i++ {
+		res := l.Allow("client")
+		if !res.Allowed {
+			t.Fatalf("request %d rejected within burst", i)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("request %d Remaining = %d, want %d", i, res.Remaining, 2-i)
+		}
+	}
+
+	res := l.Allow("client")
+	if res.Allowed {
+		t.Fatal("request over burst allowed")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %s, want 500ms", res.RetryAfter)
+	}
+	if res.Reset != 1500*time.Millisecond {
+		t.Errorf("Reset = %s, want 1.5s", res.Reset)
+	}
+
+	// корзины разных клиентов независимы
+	if !l.Allow("other").Allowed {
+		t.Error("other client rejected")
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if !l.Allow("client").Allowed {
+		t.Error("request rejected after refill")
+	}
+	if l.Allow("client").Allowed {
+		t.Error("only one token should have been refilled")
+	}
+}
+
+func TestLimiter_AllowN(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 2, Burst: 10}, nil)
+
+	granted, res := l.AllowN("client", 4)
+	if granted != 4 || !res.Allowed || res.Remaining != 6 {
+		t.Fatalf("AllowN(4) = %d, %+v, want 4 granted and 6 remaining", granted, res)
+	}
+
+	// токенов меньше, чем просят: списываются все целые
+	granted, res = l.AllowN("client", 8)
+	if granted != 6 || res.Allowed || res.Remaining != 0 {
+		t.Errorf("AllowN(8) = %d, %+v, want 6 granted and rejected", granted, res)
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %s, want 500ms", res.RetryAfter)
+	}
+
+	*now = now.Add(time.Second)
+	if granted, _ := l.AllowN("client", 5); granted != 2 {
+		t.Errorf("AllowN() after refill granted %d, want 2", granted)
+	}
+}
+
+func TestLimiter_Evict(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 1, Burst: 10}, nil)
+
+	for _, key := range []string{"a", "b", "c"} {
+		l.Allow(key)
+	}
+	if l.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", l.Len())
+	}
+
+	// через время наполнения корзины простаивающие клиенты выкидываются
+	*now = now.Add(10 * time.Second)
+	l.Allow("d")
+	if l.Len() != 1 {
+		t.Errorf("Len() after idle = %d, want 1", l.Len())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 0.5, Burst: 1}, func(r *http.Request) string { return "client" })
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/abc", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/abc", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "1" {
+		t.Errorf("RateLimit-Limit = %q, want 1", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "2" {
+		t.Errorf("RateLimit-Reset = %q, want 2", got)
+	}
+}
+
+func TestClientIP_Resolve(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() failed: %v", err)
+	}
+	clientIP := NewClientIP(trusted)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted proxy ignored", "203.0.113.5:1234", []string{"198.51.100.1"}, "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:1234", []string{"198.51.100.1, 192.168.1.1", "10.0.0.7"}, "198.51.100.1"},
+		{"spoofed left part", "10.1.2.3:1234", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"all trusted", "10.1.2.3:1234", []string{"10.0.0.9"}, "10.0.0.9"},
+		{"garbage hop", "10.1.2.3:1234", []string{"198.51.100.1, garbage"}, "10.1.2.3"},
+		{"ipv6", "[2001:db8::1]:1234", nil, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := clientIP.Resolve(req); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP_Key(t *testing.T) {
+	clientIP := NewClientIP(nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	if got := clientIP.Key(req); got != "ip:203.0.113.5" {
+		t.Errorf("Key() = %q, want ip:203.0.113.5", got)
+	}
+
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{KeyID: "abc"}))
+	if got := clientIP.Key(req); got != "key:abc" {
+		t.Errorf("Key() = %q, want key:abc", got)
+	}
+}
+
+func TestParseTrustedProxies_Invalid(t *testing.T) {
+	for _, s := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := ParseTrustedProxies(s); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded, want error", s)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package cache оборачивает URLRepository кэшем ссылок для быстрых редиректов
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"shortURL/internal/repository"
+)
+
+type Config struct {
+	// максимальное число ссылок в кэше
+	Size int
+	// сколько живет найденная ссылка
+	TTL time.Duration
+	// сколько помнить, что кода нет, 0 отключает
+	NegativeTTL time.Duration
+}
+
+// loadTimeout сколько ждем репозиторий при загрузке. Загрузка общая для
+// всех ждущих этот код, поэтому от отмены запроса, начавшего ее, она
+// отвязана и ограничена своим таймаутом
+const loadTimeout = 5 * time.Second
+
+// Stats счетчики кэша
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+type entry struct {
+	code string
+	// nil означает закэшированный ErrNotFound
+	link      *repository.Link
+	expiresAt time.Time
+}
+
+// loading загрузки одного кода, идущие прямо сейчас. generation растет при
+// инвалидации кода, чтобы загрузка, начатая до изменения, не положила в
+// кэш устаревшую ссылку
+type loading struct {
+	generation uint64
+	count      int
+}
+
+// CachedRepository кэширует Get в LRU, остальные методы идут в репозиторий
+type CachedRepository struct {
+	repo repository.URLRepository
+	cfg  Config
+	now  func() time.Time
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+	// только коды, которые сейчас грузятся, остальным поколение не нужно
+	loads map[string]*loading
+
+	group singleflight.Group
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+func NewCachedRepository(repo repository.URLRepository, cfg Config) *CachedRepository {
+	return &CachedRepository{
+		repo:  repo,
+		cfg:   cfg,
+		now:   time.Now,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		loads: make(map[string]*loading),
+	}
+}
+
+// Get отдает ссылку из кэша, одновременные промахи по одному коду
+// сворачиваются в один запрос к репозиторию
+func (c *CachedRepository) Get(ctx context.Context, shortCode string) (*repository.Link, error) {
+	if link, ok := c.lookup(shortCode); ok {
+		c.hits.Add(1)
+		if link == nil {
+			return nil, repository.ErrNotFound
+		}
+		found := *link
+		return &found, nil
+	}
+	c.misses.Add(1)
+
+	ch := c.group.DoChan(shortCode, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		return c.load(loadCtx, shortCode)
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		found := *res.Val.(*repository.Link)
+		return &found, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load читает ссылку из репозитория и кладет результат в кэш
+func (c *CachedRepository) load(ctx context.Context, shortCode string) (*repository.Link, error) {
+	c.mu.Lock()
+	l, exists := c.loads[shortCode]
+	if !exists {
+		l = &loading{}
+		c.loads[shortCode] = l
+	}
+	l.count++
+	generation := l.generation
+	c.mu.Unlock()
+
+	link, err := c.repo.Get(ctx, shortCode)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l.count--; l.count == 0 {
+		delete(c.loads, shortCode)
+	}
+	// пока читали из репозитория, ссылку успели изменить
+	if l.generation != generation {
+		return link, err
+	}
+
+	switch {
+	case err == nil:
+		c.store(shortCode, link, c.cfg.TTL)
+	case errors.Is(err, repository.ErrNotFound):
+		if c.cfg.NegativeTTL > 0 {
+			c.store(shortCode, nil, c.cfg.NegativeTTL)
+		}
+	}
+
+	return link, err
+}
+
+func (c *CachedRepository) lookup(shortCode string) (*repository.Link, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.items[shortCode]
+	if !exists {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return e.link, true
+}
+
+// store вызывается под блокировкой
+func (c *CachedRepository) store(shortCode string, link *repository.Link, ttl time.Duration) {
+	e := &entry{code: shortCode, link: link, expiresAt: c.now().Add(ttl)}
+	if elem, exists := c.items[shortCode]; exists {
+		elem.Value = e
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[shortCode] = c.order.PushFront(e)
+	for c.order.Len() > c.cfg.Size {
+		c.removeElement(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Invalidate убирает код из кэша
+func (c *CachedRepository) Invalidate(shortCode string) {
+	c.mu.Lock()
+	if l, exists := c.loads[shortCode]; exists {
+		l.generation++
+	}
+	if elem, exists := c.items[shortCode]; exists {
+		c.removeElement(elem)
+	}
+	c.mu.Unlock()
+
+	// следующие промахи не должны присоединяться к уже идущей загрузке
+	c.group.Forget(shortCode)
+}
+
+// removeElement вызывается под блокировкой
+func (c *CachedRepository) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry).code)
+}
+
+// Stats возвращает счетчики попаданий и промахов
+func (c *CachedRepository) Stats() Stats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+	}
+}
+
+func (c *CachedRepository) Save(ctx context.Context, link *repository.Link) error {
+	// код мог быть закэширован как отсутствующий
+	defer c.Invalidate(link.ShortCode)
+	return c.repo.Save(ctx, link)
+}
+
+func (c *CachedRepository) SaveMany(ctx context.Context, links []*repository.Link) ([]error, error) {
+	defer func() {
+		for _, link := range links {
+			c.Invalidate(link.ShortCode)
+		}
+	}()
+	return c.repo.SaveMany(ctx, links)
+}
+
+func (c *CachedRepository) GetByOriginal(ctx context.Context, owner, originalURL string) (string, error) {
+	return c.repo.GetByOriginal(ctx, owner, originalURL)
+}
+
+func (c *CachedRepository) Delete(ctx context.Context, shortCode string) error {
+	defer c.Invalidate(shortCode)
+	return c.repo.Delete(ctx, shortCode)
+}
+
+func (c *CachedRepository) Update(ctx context.Context, shortCode string, newURL string) error {
+	defer c.Invalidate(shortCode)
+	return c.repo.Update(ctx, shortCode, newURL)
+}
+
+func (c *CachedRepository) List(ctx context.Context, filter repository.ListFilter, cursor *repository.Cursor, limit int) (*repository.LinkPage, error) {
+	return c.repo.List(ctx, filter, cursor, limit)
+}
+
+// DeleteExpired заодно выкидывает истекшие ссылки из кэша
+func (c *CachedRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	deleted, err := c.repo.DeleteExpired(ctx, now)
+
+	c.mu.Lock()
+	for _, l := range c.loads {
+		l.generation++
+	}
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if link := elem.Value.(*entry).link; link != nil && link.Expired(now) {
+			c.removeElement(elem)
+		}
+		elem = next
+	}
+	c.mu.Unlock()
+
+	return deleted, err
+}
+
+func (c *CachedRepository) Ping(ctx context.Context) error {
+	return c.repo.Ping(ctx)
+}
+
+func (c *CachedRepository) Close() error {
+	return c.repo.Close()
+}
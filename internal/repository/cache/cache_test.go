@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shortURL/internal/repository"
+	"shortURL/internal/repository/memory"
+	"shortURL/internal/repository/repotest"
+)
+
+// countingRepository считает обращения к Get и умеет их задерживать
+type countingRepository struct {
+	repository.URLRepository
+	gets    atomic.Int32
+	release chan struct{}
+}
+
+func (r *countingRepository) Get(ctx context.Context, shortCode string) (*repository.Link, error) {
+	r.gets.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	// как настоящая база, отмененный запрос не выполняем
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.URLRepository.Get(ctx, shortCode)
+}
+
+var testConfig = Config{
+	Size:        10000,
+	TTL:         5 * time.Minute,
+	NegativeTTL: 10 * time.Second,
+}
+
+func newTestCache(cfg Config) (*CachedRepository, *countingRepository) {
+	backend := &countingRepository{URLRepository: memory.NewMemoryRepository()}
+	return NewCachedRepository(backend, cfg), backend
+}
+
+func TestCachedRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.URLRepository {
+		return NewCachedRepository(memory.NewMemoryRepository(), testConfig)
+	})
+}
+
+func TestCachedRepository_Get(t *testing.T) {
+	repo, backend := newTestCache(testConfig)
+	ctx := context.Background()
+
+	now := time.Now()
+	repo.now = func() time.Time { return now }
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+
+	for i := 0; i < 3; i++ {
+		link, err := repo.Get(ctx, "abc123XYZ_")
+		if err != nil {
+			t.Fatalf("Get() failed: %v", err)
+		}
+		if link.OriginalURL != "https://example.com/test" {
+			t.Errorf("Get() = %s, want %s", link.OriginalURL, "https://example.com/test")
+		}
+	}
+	if got := backend.gets.Load(); got != 1 {
+		t.Errorf("backend Get() called %d times, want 1", got)
+	}
+
+	stats := repo.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("Stats() = %+v, want 2 hits, 1 miss, 1 entry", stats)
+	}
+
+	// по истечении TTL ссылка перечитывается
+	now = now.Add(testConfig.TTL)
+	if _, err := repo.Get(ctx, "abc123XYZ_"); err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if got := backend.gets.Load(); got != 2 {
+		t.Errorf("backend Get() after TTL called %d times, want 2", got)
+	}
+}
+
+func TestCachedRepository_NegativeCaching(t *testing.T) {
+	repo, backend := newTestCache(testConfig)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Get(ctx, "missing___"); err != repository.ErrNotFound {
+			t.Fatalf("Get() error = %v, want %v", err, repository.ErrNotFound)
+		}
+	}
+	if got := backend.gets.Load(); got != 1 {
+		t.Errorf("backend Get() called %d times, want 1", got)
+	}
+
+	// Save сбрасывает отрицательную запись
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "missing___", OriginalURL: "https://example.com/test"})
+	if _, err := repo.Get(ctx, "missing___"); err != nil {
+		t.Errorf("Get() after Save() failed: %v", err)
+	}
+
+	// с нулевым NegativeTTL отсутствие не кэшируется
+	repo, backend = newTestCache(Config{Size: 10, TTL: time.Minute})
+	for i := 0; i < 2; i++ {
+		_, _ = repo.Get(ctx, "missing___")
+	}
+	if got := backend.gets.Load(); got != 2 {
+		t.Errorf("backend Get() without negative caching called %d times, want 2", got)
+	}
+}
+
+func TestCachedRepository_Invalidation(t *testing.T) {
+	repo, _ := newTestCache(testConfig)
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/old"})
+	_, _ = repo.Get(ctx, "abc123XYZ_")
+
+	if err := repo.Update(ctx, "abc123XYZ_", "https://example.com/new"); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	link, err := repo.Get(ctx, "abc123XYZ_")
+	if err != nil || link.OriginalURL != "https://example.com/new" {
+		t.Errorf("Get() after Update() = %v, %v", link, err)
+	}
+
+	if err := repo.Delete(ctx, "abc123XYZ_"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := repo.Get(ctx, "abc123XYZ_"); err != repository.ErrNotFound {
+		t.Errorf("Get() after Delete() error = %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func TestCachedRepository_Eviction(t *testing.T) {
+	repo, backend := newTestCache(Config{Size: 2, TTL: time.Minute})
+	ctx := context.Background()
+
+	for _, code := range []string{"aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc"} {
+		_ = repo.Save(ctx, &repository.Link{ShortCode: code, OriginalURL: "https://example.com/" + code})
+	}
+
+	_, _ = repo.Get(ctx, "aaaaaaaaaa")
+	_, _ = repo.Get(ctx, "bbbbbbbbbb")
+	_, _ = repo.Get(ctx, "aaaaaaaaaa")
+	// вытесняет bbbbbbbbbb как давно не использованный
+	_, _ = repo.Get(ctx, "cccccccccc")
+
+	backend.gets.Store(0)
+	_, _ = repo.Get(ctx, "aaaaaaaaaa")
+	if got := backend.gets.Load(); got != 0 {
+		t.Errorf("recently used code was evicted")
+	}
+	_, _ = repo.Get(ctx, "bbbbbbbbbb")
+	if got := backend.gets.Load(); got != 1 {
+		t.Errorf("least recently used code was not evicted")
+	}
+
+	if stats := repo.Stats(); stats.Entries != 2 || stats.Evictions != 2 {
+		t.Errorf("Stats() = %+v, want 2 entries, 2 evictions", stats)
+	}
+}
+
+func TestCachedRepository_Singleflight(t *testing.T) {
+	repo, backend := newTestCache(testConfig)
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+	backend.release = make(chan struct{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Get(ctx, "abc123XYZ_")
+			errs <- err
+		}()
+	}
+
+	// ждем, пока все промахнутся и встанут в ожидание
+	deadline := time.Now().Add(time.Second)
+	for repo.Stats().Misses < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(backend.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Get() failed: %v", err)
+		}
+	}
+	if got := backend.gets.Load(); got != 1 {
+		t.Errorf("backend Get() called %d times, want 1", got)
+	}
+}
+
+func TestCachedRepository_StaleLoad(t *testing.T) {
+	repo, backend := newTestCache(testConfig)
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/old"})
+	backend.release = make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = repo.Get(ctx, "abc123XYZ_")
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for backend.gets.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// ссылку меняют, пока идет загрузка старого значения
+	repo.Invalidate("abc123XYZ_")
+	close(backend.release)
+	<-done
+
+	if stats := repo.Stats(); stats.Entries != 0 {
+		t.Errorf("stale load was cached, Stats() = %+v", stats)
+	}
+}
+
+func TestCachedRepository_UnrelatedInvalidation(t *testing.T) {
+	repo, backend := newTestCache(testConfig)
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+	backend.release = make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = repo.Get(ctx, "abc123XYZ_")
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for backend.gets.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// запись другого кода не мешает закэшировать загружаемый
+	repo.Invalidate("other_code")
+	close(backend.release)
+	<-done
+
+	if stats := repo.Stats(); stats.Entries != 1 {
+		t.Errorf("load was discarded by unrelated write, Stats() = %+v", stats)
+	}
+}
+
+func TestCachedRepository_CanceledLeader(t *testing.T) {
+	repo, backend := newTestCache(testConfig)
+
+	_ = repo.Save(context.Background(), &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+	backend.release = make(chan struct{})
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := repo.Get(leaderCtx, "abc123XYZ_")
+		leader <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for backend.gets.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	follower := make(chan error, 1)
+	go func() {
+		_, err := repo.Get(context.Background(), "abc123XYZ_")
+		follower <- err
+	}()
+	for repo.Stats().Misses < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// первый клиент ушел, загрузка для остальных продолжается
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Errorf("leader Get() error = %v, want %v", err, context.Canceled)
+	}
+	close(backend.release)
+
+	if err := <-follower; err != nil {
+		t.Errorf("follower Get() failed: %v", err)
+	}
+}
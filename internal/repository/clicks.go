@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Click переход по короткой ссылке
+type Click struct {
+	ShortCode string
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+
+	// IPHash хеш адреса клиента, сам адрес не храним
+	IPHash string
+}
+
+// ClickBucket количество переходов за интервал, начинающийся в Start
+type ClickBucket struct {
+	Start  time.Time
+	Clicks int64
+}
+
+// ClickStats агрегированная статистика переходов по ссылке
+type ClickStats struct {
+	Total          int64
+	UniqueVisitors int64
+	Daily          []ClickBucket
+	Hourly         []ClickBucket
+}
+
+type ClickRepository interface {
+	// SaveClicks сохраняет пачку переходов
+	SaveClicks(ctx context.Context, clicks []Click) error
+
+	// ClickStats считает статистику переходов по ссылке начиная с since
+	ClickStats(ctx context.Context, shortCode string, since time.Time) (*ClickStats, error)
+}
+
+// AggregateClicks считает статистику по переходам одной ссылки в памяти
+func AggregateClicks(clicks []Click, since time.Time) *ClickStats {
+	stats := &ClickStats{}
+	visitors := make(map[string]struct{})
+	daily := make(map[time.Time]int64)
+	hourly := make(map[time.Time]int64)
+
+	for _, click := range clicks {
+		if click.ClickedAt.Before(since) {
+			continue
+		}
+
+		stats.Total++
+		visitors[click.IPHash] = struct{}{}
+
+		at := click.ClickedAt.UTC()
+		daily[at.Truncate(24*time.Hour)]++
+		hourly[at.Truncate(time.Hour)]++
+	}
+
+	stats.UniqueVisitors = int64(len(visitors))
+	stats.Daily = sortedBuckets(daily)
+	stats.Hourly = sortedBuckets(hourly)
+
+	return stats
+}
+
+func sortedBuckets(counts map[time.Time]int64) []ClickBucket {
+	buckets := make([]ClickBucket, 0, len(counts))
+	for start, clicks := range counts {
+		buckets = append(buckets, ClickBucket{Start: start, Clicks: clicks})
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+
+	return buckets
+}
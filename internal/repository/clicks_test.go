@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregateClicks(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	clicks := []Click{
+		{ClickedAt: day.Add(-time.Hour), IPHash: "old"},
+		{ClickedAt: day.Add(9 * time.Hour), IPHash: "a"},
+		{ClickedAt: day.Add(9*time.Hour + 30*time.Minute), IPHash: "b"},
+		{ClickedAt: day.Add(10 * time.Hour), IPHash: "a"},
+		{ClickedAt: day.Add(26 * time.Hour), IPHash: "c"},
+	}
+
+	stats := AggregateClicks(clicks, day)
+
+	if stats.Total != 4 {
+		t.Errorf("Total = %d, want 4", stats.Total)
+	}
+	if stats.UniqueVisitors != 3 {
+		t.Errorf("UniqueVisitors = %d, want 3", stats.UniqueVisitors)
+	}
+
+	wantDaily := []ClickBucket{
+		{Start: day, Clicks: 3},
+		{Start: day.Add(24 * time.Hour), Clicks: 1},
+	}
+	if len(stats.Daily) != len(wantDaily) {
+		t.Fatalf("Daily = %v, want %v", stats.Daily, wantDaily)
+	}
+	for i := range wantDaily {
+		if !stats.Daily[i].Start.Equal(wantDaily[i].Start) || stats.Daily[i].Clicks != wantDaily[i].Clicks {
+			t.Errorf("Daily[%d] = %v, want %v", i, stats.Daily[i], wantDaily[i])
+		}
+	}
+
+	if len(stats.Hourly) != 3 {
+		t.Fatalf("Hourly has %d buckets, want 3", len(stats.Hourly))
+	}
+	if stats.Hourly[0].Clicks != 2 {
+		t.Errorf("Hourly[0].Clicks = %d, want 2", stats.Hourly[0].Clicks)
+	}
+}
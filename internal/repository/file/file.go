@@ -0,0 +1,640 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"shortURL/internal/repository"
+)
+
+var (
+	// linksBucket short_code -> record
+	linksBucket = []byte("links")
+
+	// originalsBucket repository.OriginalKey -> short_code
+	originalsBucket = []byte("originals")
+
+	// createdBucket created_at+id -> short_code, индекс для List
+	createdBucket = []byte("created")
+
+	// clicksBucket вложенный бакет на каждый short_code: seq -> click
+	clicksBucket = []byte("clicks")
+
+	// keysBucket id -> API ключ
+	keysBucket = []byte("api_keys")
+
+	// sequenceBucket пустой, нужен только ради счетчика NextSequence
+	sequenceBucket = []byte("sequence")
+)
+
+// record ссылка в том виде, в котором лежит в файле
+type record struct {
+	ID          int64      `json:"id"`
+	OriginalURL string     `json:"original_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Owner       string     `json:"owner,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	RedirectStatus int `json:"redirect_status,omitempty"`
+}
+
+func (rec *record) link(shortCode string) *repository.Link {
+	return &repository.Link{
+		ID:          rec.ID,
+		ShortCode:   shortCode,
+		OriginalURL: rec.OriginalURL,
+		CreatedAt:   rec.CreatedAt,
+		Owner:       rec.Owner,
+		ExpiresAt:   rec.ExpiresAt,
+
+		RedirectStatus: rec.RedirectStatus,
+	}
+}
+
+// keyRecord API ключ в том виде, в котором лежит в файле
+type keyRecord struct {
+	Name       string     `json:"name"`
+	SecretHash string     `json:"secret_hash"`
+	Admin      bool       `json:"admin,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (rec *keyRecord) key(id string) *repository.APIKey {
+	return &repository.APIKey{
+		ID:         id,
+		Name:       rec.Name,
+		SecretHash: rec.SecretHash,
+		Admin:      rec.Admin,
+		CreatedAt:  rec.CreatedAt,
+		RevokedAt:  rec.RevokedAt,
+	}
+}
+
+// Expired истекла ли ссылка к моменту now
+func (rec *record) Expired(now time.Time) bool {
+	return rec.ExpiresAt != nil && !rec.ExpiresAt.After(now)
+}
+
+// FileRepository хранит ссылки во встроенной базе bbolt в одном файле
+type FileRepository struct {
+	db *bolt.DB
+}
+
+// NewFileRepository открывает или создает файл базы по пути path
+func NewFileRepository(path string) (*FileRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database file: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{linksBucket, originalsBucket, createdBucket, clicksBucket, keysBucket, sequenceBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	return &FileRepository{db: db}, nil
+}
+
+// Save сохраняет новый shortURL
+func (r *FileRepository) Save(ctx context.Context, link *repository.Link) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return saveLink(tx, link, time.Now())
+	})
+}
+
+// SaveMany сохраняет ссылки в одной транзакции
+func (r *FileRepository) SaveMany(ctx context.Context, links []*repository.Link) ([]error, error) {
+	errs := make([]error, len(links))
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for i, link := range links {
+			err := saveLink(tx, link, now)
+			if err != nil && !errors.Is(err, repository.ErrAlreadyExists) && !errors.Is(err, repository.ErrDuplicate) {
+				return err
+			}
+			errs[i] = err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// saveLink проверяет и сохраняет ссылку внутри транзакции
+func saveLink(tx *bolt.Tx, link *repository.Link, now time.Time) error {
+	existing, err := getRecord(tx, link.ShortCode)
+	if err != nil {
+		return err
+	}
+	if existing != nil && !existing.Expired(now) {
+		if existing.OriginalURL == link.OriginalURL && existing.Owner == link.Owner {
+			return nil
+		}
+		return repository.ErrAlreadyExists
+	}
+
+	key := []byte(repository.OriginalKey(link.Owner, link.OriginalURL))
+	if existingShort := tx.Bucket(originalsBucket).Get(key); existingShort != nil {
+		other, err := getRecord(tx, string(existingShort))
+		if err != nil {
+			return err
+		}
+		if other != nil && !other.Expired(now) {
+			return repository.ErrDuplicate
+		}
+	}
+
+	// истекшие ссылки с тем же кодом или URL перезаписываем
+	if err := removeLink(tx, link.ShortCode); err != nil {
+		return err
+	}
+	if existingShort := tx.Bucket(originalsBucket).Get(key); existingShort != nil {
+		if err := removeLink(tx, string(existingShort)); err != nil {
+			return err
+		}
+	}
+
+	id, err := tx.Bucket(linksBucket).NextSequence()
+	if err != nil {
+		return err
+	}
+
+	rec := &record{
+		ID:          int64(id),
+		OriginalURL: link.OriginalURL,
+		CreatedAt:   link.CreatedAt,
+		Owner:       link.Owner,
+		ExpiresAt:   link.ExpiresAt,
+
+		RedirectStatus: link.RedirectStatus,
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now.UTC()
+	}
+
+	if err := putLink(tx, link.ShortCode, rec); err != nil {
+		return err
+	}
+
+	link.ID = rec.ID
+	link.CreatedAt = rec.CreatedAt
+	return nil
+}
+
+// Get получает ссылку по shortURL
+func (r *FileRepository) Get(ctx context.Context, shortCode string) (*repository.Link, error) {
+	var link *repository.Link
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		rec, err := getRecord(tx, shortCode)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return repository.ErrNotFound
+		}
+
+		link = rec.link(shortCode)
+		return nil
+	})
+
+	return link, err
+}
+
+// NextSequence выдает следующий номер из счетчика бакета, он сохраняется в файле
+func (r *FileRepository) NextSequence(ctx context.Context) (uint64, error) {
+	var n uint64
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.Bucket(sequenceBucket).NextSequence()
+		return err
+	})
+
+	return n, err
+}
+
+// GetByOriginal получает shortURL владельца по оригу
+func (r *FileRepository) GetByOriginal(ctx context.Context, owner, originalURL string) (string, error) {
+	var shortCode string
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		code := tx.Bucket(originalsBucket).Get([]byte(repository.OriginalKey(owner, originalURL)))
+		if code == nil {
+			return repository.ErrNotFound
+		}
+
+		rec, err := getRecord(tx, string(code))
+		if err != nil {
+			return err
+		}
+		if rec == nil || rec.Expired(time.Now()) {
+			return repository.ErrNotFound
+		}
+
+		shortCode = string(code)
+		return nil
+	})
+
+	return shortCode, err
+}
+
+// Delete удаляет ссылку и ее переходы
+func (r *FileRepository) Delete(ctx context.Context, shortCode string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(linksBucket).Get([]byte(shortCode)) == nil {
+			return repository.ErrNotFound
+		}
+		return removeLink(tx, shortCode)
+	})
+}
+
+// Update перенаправляет shortURL на новый URL
+func (r *FileRepository) Update(ctx context.Context, shortCode string, newURL string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		rec, err := getRecord(tx, shortCode)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return repository.ErrNotFound
+		}
+		if rec.OriginalURL == newURL {
+			return nil
+		}
+
+		originals := tx.Bucket(originalsBucket)
+
+		// новый URL уже сокращен тем же владельцем под другим кодом
+		if existingShort := originals.Get([]byte(repository.OriginalKey(rec.Owner, newURL))); existingShort != nil {
+			other, err := getRecord(tx, string(existingShort))
+			if err != nil {
+				return err
+			}
+			if other != nil && !other.Expired(time.Now()) {
+				return repository.ErrDuplicate
+			}
+			if err := removeLink(tx, string(existingShort)); err != nil {
+				return err
+			}
+		}
+
+		oldKey := []byte(repository.OriginalKey(rec.Owner, rec.OriginalURL))
+		if bytes.Equal(originals.Get(oldKey), []byte(shortCode)) {
+			if err := originals.Delete(oldKey); err != nil {
+				return err
+			}
+		}
+
+		rec.OriginalURL = newURL
+		return putLink(tx, shortCode, rec)
+	})
+}
+
+// List возвращает страницу ссылок от новых к старым, идя по индексу created
+func (r *FileRepository) List(ctx context.Context, filter repository.ListFilter, cursor *repository.Cursor, limit int) (*repository.LinkPage, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	page := &repository.LinkPage{}
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(createdBucket).Cursor()
+
+		var k, v []byte
+		if cursor == nil {
+			k, v = c.Last()
+		} else {
+			// Seek встает на ключ курсора или следующий за ним, нам нужны строго меньшие
+			k, v = c.Seek(createdKey(cursor.CreatedAt, cursor.ID))
+			if k == nil {
+				k, v = c.Last()
+			}
+			for k != nil && bytes.Compare(k, createdKey(cursor.CreatedAt, cursor.ID)) >= 0 {
+				k, v = c.Prev()
+			}
+		}
+
+		for ; k != nil; k, v = c.Prev() {
+			rec, err := getRecord(tx, string(v))
+			if err != nil {
+				return err
+			}
+			if rec == nil {
+				continue
+			}
+
+			link := rec.link(string(v))
+			if !filter.Match(link) {
+				continue
+			}
+
+			if len(page.Links) == limit {
+				page.Next = repository.CursorAfter(page.Links[limit-1])
+				return nil
+			}
+			page.Links = append(page.Links, link)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// DeleteExpired удаляет истекшие ссылки и их переходы
+func (r *FileRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		var expired []string
+		err := tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if rec.Expired(now) {
+				expired = append(expired, string(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, shortCode := range expired {
+			if err := removeLink(tx, shortCode); err != nil {
+				return err
+			}
+		}
+
+		deleted = int64(len(expired))
+		return nil
+	})
+
+	return deleted, err
+}
+
+// SaveClicks сохраняет пачку переходов
+func (r *FileRepository) SaveClicks(ctx context.Context, clicks []repository.Click) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(clicksBucket)
+		for _, click := range clicks {
+			bucket, err := root.CreateBucketIfNotExists([]byte(click.ShortCode))
+			if err != nil {
+				return err
+			}
+
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(click)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(binary.BigEndian.AppendUint64(nil, seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClickStats считает статистику переходов по ссылке
+func (r *FileRepository) ClickStats(ctx context.Context, shortCode string, since time.Time) (*repository.ClickStats, error) {
+	var clicks []repository.Click
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(clicksBucket).Bucket([]byte(shortCode))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var click repository.Click
+			if err := json.Unmarshal(v, &click); err != nil {
+				return err
+			}
+			clicks = append(clicks, click)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clicks: %w", err)
+	}
+
+	return repository.AggregateClicks(clicks, since), nil
+}
+
+// SaveKey сохраняет новый API ключ
+func (r *FileRepository) SaveKey(ctx context.Context, key *repository.APIKey) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		if bucket.Get([]byte(key.ID)) != nil {
+			return repository.ErrAlreadyExists
+		}
+
+		rec := &keyRecord{
+			Name:       key.Name,
+			SecretHash: key.SecretHash,
+			Admin:      key.Admin,
+			CreatedAt:  key.CreatedAt,
+		}
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = time.Now().UTC()
+		}
+
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte(key.ID), data); err != nil {
+			return err
+		}
+
+		key.CreatedAt = rec.CreatedAt
+		return nil
+	})
+}
+
+// GetKey получает API ключ по id
+func (r *FileRepository) GetKey(ctx context.Context, id string) (*repository.APIKey, error) {
+	var key *repository.APIKey
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(keysBucket).Get([]byte(id))
+		if data == nil {
+			return repository.ErrNotFound
+		}
+
+		var rec keyRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		key = rec.key(id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ListKeys возвращает ключи от старых к новым
+func (r *FileRepository) ListKeys(ctx context.Context) ([]*repository.APIKey, error) {
+	var keys []*repository.APIKey
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(keysBucket).ForEach(func(k, v []byte) error {
+			var rec keyRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			keys = append(keys, rec.key(string(k)))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+// RevokeKey отзывает API ключ
+func (r *FileRepository) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return repository.ErrNotFound
+		}
+
+		var rec keyRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		if rec.RevokedAt != nil {
+			return nil
+		}
+		rec.RevokedAt = &at
+
+		data, err := json.Marshal(&rec)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), data)
+	})
+}
+
+// Ping открывает пустую транзакцию, после Close вернет ошибку
+func (r *FileRepository) Ping(ctx context.Context) error {
+	return r.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+func (r *FileRepository) Close() error {
+	return r.db.Close()
+}
+
+// getRecord читает ссылку, nil если ее нет
+func getRecord(tx *bolt.Tx, shortCode string) (*record, error) {
+	data := tx.Bucket(linksBucket).Get([]byte(shortCode))
+	if data == nil {
+		return nil, nil
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode link %s: %w", shortCode, err)
+	}
+
+	return &rec, nil
+}
+
+// putLink записывает ссылку во все бакеты
+func putLink(tx *bolt.Tx, shortCode string, rec *record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(linksBucket).Put([]byte(shortCode), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(originalsBucket).Put([]byte(repository.OriginalKey(rec.Owner, rec.OriginalURL)), []byte(shortCode)); err != nil {
+		return err
+	}
+	return tx.Bucket(createdBucket).Put(createdKey(rec.CreatedAt, rec.ID), []byte(shortCode))
+}
+
+// removeLink удаляет ссылку из всех бакетов вместе с переходами
+func removeLink(tx *bolt.Tx, shortCode string) error {
+	rec, err := getRecord(tx, shortCode)
+	if err != nil || rec == nil {
+		return err
+	}
+
+	if err := tx.Bucket(linksBucket).Delete([]byte(shortCode)); err != nil {
+		return err
+	}
+
+	originals := tx.Bucket(originalsBucket)
+	key := []byte(repository.OriginalKey(rec.Owner, rec.OriginalURL))
+	if bytes.Equal(originals.Get(key), []byte(shortCode)) {
+		if err := originals.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Bucket(createdBucket).Delete(createdKey(rec.CreatedAt, rec.ID)); err != nil {
+		return err
+	}
+
+	err = tx.Bucket(clicksBucket).DeleteBucket([]byte(shortCode))
+	if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// createdKey ключ индекса: время создания и id в big endian, чтобы порядок байт совпадал с порядком выдачи
+func createdKey(createdAt time.Time, id int64) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(createdAt.UnixNano()))
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
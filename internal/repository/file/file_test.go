@@ -0,0 +1,90 @@
+package file
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shortURL/internal/repository"
+	"shortURL/internal/repository/repotest"
+)
+
+func newTestRepository(t *testing.T, path string) *FileRepository {
+	t.Helper()
+
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() failed: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestFileRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.URLRepository {
+		return newTestRepository(t, filepath.Join(t.TempDir(), "shorturl.db"))
+	})
+}
+
+func TestFileRepository_Keys(t *testing.T) {
+	repotest.RunKeys(t, func(t *testing.T) repository.KeyRepository {
+		return newTestRepository(t, filepath.Join(t.TempDir(), "shorturl.db"))
+	})
+}
+
+func TestFileRepository_Sequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) repository.SequenceRepository {
+		return newTestRepository(t, filepath.Join(t.TempDir(), "shorturl.db"))
+	})
+}
+
+func TestFileRepository_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shorturl.db")
+	ctx := context.Background()
+
+	repo, err := NewFileRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileRepository() failed: %v", err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test", ExpiresAt: &future}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := repo.SaveClicks(ctx, []repository.Click{{ShortCode: "abc123XYZ_", ClickedAt: time.Now(), IPHash: "a"}}); err != nil {
+		t.Fatalf("SaveClicks() failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	// после переоткрытия файла данные на месте
+	repo = newTestRepository(t, path)
+
+	link, err := repo.Get(ctx, "abc123XYZ_")
+	if err != nil {
+		t.Fatalf("Get() after reopen failed: %v", err)
+	}
+	if link.OriginalURL != "https://example.com/test" || link.ExpiresAt == nil || !link.ExpiresAt.Equal(future) {
+		t.Errorf("Get() after reopen = %+v", link)
+	}
+
+	stats, err := repo.ClickStats(ctx, "abc123XYZ_", time.Time{})
+	if err != nil {
+		t.Fatalf("ClickStats() failed: %v", err)
+	}
+	if stats.Total != 1 {
+		t.Errorf("ClickStats() total = %d, want 1", stats.Total)
+	}
+
+	// новые id продолжают последовательность
+	next := &repository.Link{ShortCode: "xyz789ABC_", OriginalURL: "https://example.com/next"}
+	if err := repo.Save(ctx, next); err != nil {
+		t.Fatalf("Save() after reopen failed: %v", err)
+	}
+	if next.ID <= link.ID {
+		t.Errorf("Save() after reopen ID = %d, want greater than %d", next.ID, link.ID)
+	}
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// APIKey ключ доступа к API, сам секрет не хранится, только его хэш
+type APIKey struct {
+	// ID публичная часть ключа, по ней ключ ищется и она же пишется владельцем ссылок
+	ID         string
+	Name       string
+	SecretHash string
+	Admin      bool
+	CreatedAt  time.Time
+
+	// RevokedAt момент отзыва, nil если ключ действует
+	RevokedAt *time.Time
+}
+
+// Revoked отозван ли ключ
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// KeyRepository хранилище API ключей
+type KeyRepository interface {
+	// SaveKey сохраняет новый ключ и заполняет CreatedAt, ErrAlreadyExists если id занят
+	SaveKey(ctx context.Context, key *APIKey) error
+
+	// GetKey получает ключ по id, в том числе отозванный
+	GetKey(ctx context.Context, id string) (*APIKey, error)
+
+	// ListKeys возвращает все ключи от старых к новым
+	ListKeys(ctx context.Context) ([]*APIKey, error)
+
+	// RevokeKey отзывает ключ, повторный отзыв не меняет время отзыва
+	RevokeKey(ctx context.Context, id string, at time.Time) error
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListFilter условия выборки ссылок, пустые поля не учитываются
+type ListFilter struct {
+	// Query подстрока оригинального URL без учета регистра
+	Query string
+
+	// Host хост оригинального URL без учета регистра
+	Host string
+
+	// Owner только ссылки этого владельца
+	Owner string
+}
+
+// Match подходит ли ссылка под фильтр
+func (f ListFilter) Match(link *Link) bool {
+	if f.Owner != "" && link.Owner != f.Owner {
+		return false
+	}
+
+	if f.Query != "" && !strings.Contains(strings.ToLower(link.OriginalURL), strings.ToLower(f.Query)) {
+		return false
+	}
+
+	if f.Host != "" {
+		parsed, err := url.Parse(link.OriginalURL)
+		if err != nil || !strings.EqualFold(parsed.Hostname(), f.Host) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Cursor позиция в выдаче: ссылка, после которой начинается следующая страница
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// CursorAfter курсор, указывающий на ссылку link
+func CursorAfter(link *Link) *Cursor {
+	return &Cursor{CreatedAt: link.CreatedAt, ID: link.ID}
+}
+
+// Before идет ли ссылка в выдаче после курсора, то есть старше его
+func (c *Cursor) Before(link *Link) bool {
+	if link.CreatedAt.Equal(c.CreatedAt) {
+		return link.ID < c.ID
+	}
+	return link.CreatedAt.Before(c.CreatedAt)
+}
+
+// Encode кодирует курсор в непрозрачную строку для API
+func (c *Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor разбирает строку, полученную из Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	i, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: time.Unix(0, n).UTC(), ID: i}, nil
+}
+
+// LinkPage страница выдачи, Next равен nil на последней странице
+type LinkPage struct {
+	Links []*Link
+	Next  *Cursor
+}
@@ -2,85 +2,431 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
 	"sync"
+	"time"
 
 	"shortURL/internal/repository"
 )
 
 type MemoryRepository struct {
 	mu              sync.RWMutex
-	shortToOriginal map[string]string
-	originalToShort map[string]string
+	links           map[string]*repository.Link
+	originalToShort map[string]string // repository.OriginalKey -> short_code
+	lastID          int64
+
+	// seq счетчик для последовательных кодов, не меньше lastID
+	seq uint64
+
+	clicksMu sync.RWMutex
+	clicks   map[string][]repository.Click
+
+	// ключи API под той же блокировкой mu, что и ссылки
+	keys map[string]*repository.APIKey
+
+	// persist пишет изменения на диск, nil если репозиторий только в памяти
+	persist *persister
 }
 
 func NewMemoryRepository() *MemoryRepository {
 	return &MemoryRepository{
-		shortToOriginal: make(map[string]string),
+		links:           make(map[string]*repository.Link),
 		originalToShort: make(map[string]string),
+		clicks:          make(map[string][]repository.Click),
+		keys:            make(map[string]*repository.APIKey),
 	}
 }
 
 // Save сохраняет новый shortURL
-func (r *MemoryRepository) Save(ctx context.Context, shortCode string, originalURL string) error {
+func (r *MemoryRepository) Save(ctx context.Context, link *repository.Link) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.save(link, time.Now())
+}
+
+// SaveMany сохраняет ссылки под одной блокировкой
+func (r *MemoryRepository) SaveMany(ctx context.Context, links []*repository.Link) ([]error, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	now := time.Now()
+	errs := make([]error, len(links))
+	for i, link := range links {
+		err := r.save(link, now)
+		if err != nil && !errors.Is(err, repository.ErrAlreadyExists) && !errors.Is(err, repository.ErrDuplicate) {
+			return nil, err
+		}
+		errs[i] = err
+	}
+
+	return errs, nil
+}
+
+// save проверяет и сохраняет ссылку, вызывается под блокировкой
+func (r *MemoryRepository) save(link *repository.Link, now time.Time) error {
 	// проверка на существование этого URL
-	if existingURL, exists := r.shortToOriginal[shortCode]; exists {
-		if existingURL == originalURL {
+	if existing, exists := r.links[link.ShortCode]; exists && !existing.Expired(now) {
+		if existing.OriginalURL == link.OriginalURL && existing.Owner == link.Owner {
 			return nil
 		}
 		return repository.ErrAlreadyExists
 	}
 
-	// проверяем есть ли у ориг URL shortURL
-	if existingShort, exists := r.originalToShort[originalURL]; exists {
-		if existingShort != shortCode {
+	// проверяем есть ли у ориг URL shortURL этого владельца
+	if existingShort, exists := r.originalToShort[repository.OriginalKey(link.Owner, link.OriginalURL)]; exists {
+		if existing := r.links[existingShort]; !existing.Expired(now) {
 			return repository.ErrDuplicate
 		}
-		return nil
 	}
 
-	r.shortToOriginal[shortCode] = originalURL
-	r.originalToShort[originalURL] = shortCode
+	stored := *link
+	stored.ID = r.lastID + 1
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = now.UTC()
+	}
+
+	if err := r.writeLog(logEntry{Op: opSave, Link: newLinkRecord(&stored)}); err != nil {
+		return err
+	}
+	r.applySave(&stored)
+
+	link.ID = stored.ID
+	link.CreatedAt = stored.CreatedAt
 
 	return nil
 }
 
-// Get Получиет ориг URL по shortURL
-func (r *MemoryRepository) Get(ctx context.Context, shortCode string) (string, error) {
+// applySave кладет ссылку в мапы, вытесняя ссылки с тем же кодом или URL.
+// Вызывается под блокировкой после всех проверок и при восстановлении
+func (r *MemoryRepository) applySave(link *repository.Link) {
+	key := repository.OriginalKey(link.Owner, link.OriginalURL)
+
+	r.remove(link.ShortCode)
+	if existingShort, exists := r.originalToShort[key]; exists {
+		r.remove(existingShort)
+	}
+
+	r.links[link.ShortCode] = link
+	r.originalToShort[key] = link.ShortCode
+	if link.ID > r.lastID {
+		r.lastID = link.ID
+	}
+}
+
+// Get Получиет ссылку по shortURL
+func (r *MemoryRepository) Get(ctx context.Context, shortCode string) (*repository.Link, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	originalURL, exists := r.shortToOriginal[shortCode]
+	link, exists := r.links[shortCode]
 	if !exists {
-		return "", repository.ErrNotFound
+		return nil, repository.ErrNotFound
 	}
 
-	return originalURL, nil
+	found := *link
+	return &found, nil
 }
 
-// GetByOriginal получает shortURL по оригу
-func (r *MemoryRepository) GetByOriginal(ctx context.Context, originalURL string) (string, error) {
+// GetByOriginal получает shortURL владельца по оригу
+func (r *MemoryRepository) GetByOriginal(ctx context.Context, owner, originalURL string) (string, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	shortCode, exists := r.originalToShort[originalURL]
-	if !exists {
+	shortCode, exists := r.originalToShort[repository.OriginalKey(owner, originalURL)]
+	if !exists || r.links[shortCode].Expired(time.Now()) {
 		return "", repository.ErrNotFound
 	}
 
 	return shortCode, nil
 }
 
-func (r *MemoryRepository) Close() error {
+// Delete удаляет ссылку и ее переходы
+func (r *MemoryRepository) Delete(ctx context.Context, shortCode string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.links[shortCode]; !exists {
+		return repository.ErrNotFound
+	}
+
+	if err := r.writeLog(logEntry{Op: opDelete, Code: shortCode}); err != nil {
+		return err
+	}
+	r.remove(shortCode)
+
+	return nil
+}
+
+// Update перенаправляет shortURL на новый URL
+func (r *MemoryRepository) Update(ctx context.Context, shortCode string, newURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, exists := r.links[shortCode]
+	if !exists {
+		return repository.ErrNotFound
+	}
+	if link.OriginalURL == newURL {
+		return nil
+	}
+
+	// новый URL уже сокращен тем же владельцем под другим кодом
+	if existingShort, exists := r.originalToShort[repository.OriginalKey(link.Owner, newURL)]; exists {
+		if !r.links[existingShort].Expired(time.Now()) {
+			return repository.ErrDuplicate
+		}
+	}
+
+	if err := r.writeLog(logEntry{Op: opUpdate, Code: shortCode, URL: newURL}); err != nil {
+		return err
+	}
+	r.applyUpdate(shortCode, newURL)
+
+	return nil
+}
+
+// applyUpdate перенаправляет ссылку, вытесняя другую ссылку на тот же URL.
+// Вызывается под блокировкой после всех проверок и при восстановлении
+func (r *MemoryRepository) applyUpdate(shortCode string, newURL string) {
+	link, exists := r.links[shortCode]
+	if !exists {
+		return
+	}
+
+	newKey := repository.OriginalKey(link.Owner, newURL)
+	if existingShort, exists := r.originalToShort[newKey]; exists && existingShort != shortCode {
+		r.remove(existingShort)
+	}
+
+	oldKey := repository.OriginalKey(link.Owner, link.OriginalURL)
+	if r.originalToShort[oldKey] == shortCode {
+		delete(r.originalToShort, oldKey)
+	}
+
+	link.OriginalURL = newURL
+	r.originalToShort[newKey] = shortCode
+}
+
+// NextSequence выдает следующий номер. Номер пишется в журнал до выдачи,
+// поэтому после рестарта выданные номера не повторяются
+func (r *MemoryRepository) NextSequence(ctx context.Context) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := max(r.seq, uint64(r.lastID)) + 1
+	if err := r.writeLog(logEntry{Op: opSequence, Seq: next}); err != nil {
+		return 0, err
+	}
+	r.seq = next
+	return next, nil
+}
+
+// List возвращает страницу ссылок от новых к старым
+func (r *MemoryRepository) List(ctx context.Context, filter repository.ListFilter, cursor *repository.Cursor, limit int) (*repository.LinkPage, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	r.mu.RLock()
+	matched := make([]*repository.Link, 0, len(r.links))
+	for _, link := range r.links {
+		if cursor != nil && !cursor.Before(link) {
+			continue
+		}
+		if !filter.Match(link) {
+			continue
+		}
+		found := *link
+		matched = append(matched, &found)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].ID > matched[j].ID
+		}
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	page := &repository.LinkPage{Links: matched}
+	if len(matched) > limit {
+		page.Links = matched[:limit]
+		page.Next = repository.CursorAfter(page.Links[limit-1])
+	}
+
+	return page, nil
+}
+
+// DeleteExpired удаляет истекшие ссылки
+func (r *MemoryRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for shortCode, link := range r.links {
+		if link.Expired(now) {
+			if err := r.writeLog(logEntry{Op: opDelete, Code: shortCode}); err != nil {
+				return deleted, err
+			}
+			r.remove(shortCode)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// SaveClicks сохраняет переходы
+func (r *MemoryRepository) SaveClicks(ctx context.Context, clicks []repository.Click) error {
+	r.clicksMu.Lock()
+	defer r.clicksMu.Unlock()
+
+	for _, click := range clicks {
+		r.clicks[click.ShortCode] = append(r.clicks[click.ShortCode], click)
+	}
+
+	return nil
+}
+
+// ClickStats считает статистику переходов по ссылке
+func (r *MemoryRepository) ClickStats(ctx context.Context, shortCode string, since time.Time) (*repository.ClickStats, error) {
+	r.clicksMu.RLock()
+	defer r.clicksMu.RUnlock()
+
+	return repository.AggregateClicks(r.clicks[shortCode], since), nil
+}
+
+// SaveKey сохраняет новый API ключ
+func (r *MemoryRepository) SaveKey(ctx context.Context, key *repository.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.keys[key.ID]; exists {
+		return repository.ErrAlreadyExists
+	}
+
+	stored := *key
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = time.Now().UTC()
+	}
+
+	if err := r.writeLog(logEntry{Op: opSaveKey, Key: newKeyRecord(&stored)}); err != nil {
+		return err
+	}
+	r.keys[stored.ID] = &stored
+
+	key.CreatedAt = stored.CreatedAt
+
 	return nil
 }
 
+// GetKey получает API ключ по id
+func (r *MemoryRepository) GetKey(ctx context.Context, id string) (*repository.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+
+	found := *key
+	return &found, nil
+}
+
+// ListKeys возвращает ключи от старых к новым
+func (r *MemoryRepository) ListKeys(ctx context.Context) ([]*repository.APIKey, error) {
+	r.mu.RLock()
+	keys := make([]*repository.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		found := *key
+		keys = append(keys, &found)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+// RevokeKey отзывает API ключ
+func (r *MemoryRepository) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return repository.ErrNotFound
+	}
+	if key.Revoked() {
+		return nil
+	}
+
+	if err := r.writeLog(logEntry{Op: opRevokeKey, Code: id, At: &at}); err != nil {
+		return err
+	}
+	r.applyRevokeKey(id, at)
+
+	return nil
+}
+
+// applyRevokeKey вызывается под блокировкой и при восстановлении
+func (r *MemoryRepository) applyRevokeKey(id string, at time.Time) {
+	if key, exists := r.keys[id]; exists && !key.Revoked() {
+		revokedAt := at
+		key.RevokedAt = &revokedAt
+	}
+}
+
+// Ping память доступна всегда
+func (r *MemoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close для репозитория с сохранением на диск пишет финальный снапшот
+func (r *MemoryRepository) Close() error {
+	return r.closePersister()
+}
+
 func (r *MemoryRepository) Clear() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.shortToOriginal = make(map[string]string)
+	if err := r.writeLog(logEntry{Op: opClear}); err != nil {
+		slog.Error("failed to log clear", "error", err)
+	}
+
+	r.links = make(map[string]*repository.Link)
 	r.originalToShort = make(map[string]string)
+
+	r.clicksMu.Lock()
+	r.clicks = make(map[string][]repository.Click)
+	r.clicksMu.Unlock()
+}
+
+// remove удаляет ссылку из обеих мап и ее переходы, вызывается под блокировкой
+func (r *MemoryRepository) remove(shortCode string) {
+	link, exists := r.links[shortCode]
+	if !exists {
+		return
+	}
+
+	delete(r.links, shortCode)
+	key := repository.OriginalKey(link.Owner, link.OriginalURL)
+	if r.originalToShort[key] == shortCode {
+		delete(r.originalToShort, key)
+	}
+
+	r.clicksMu.Lock()
+	delete(r.clicks, shortCode)
+	r.clicksMu.Unlock()
 }
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"shortURL/internal/repository"
+	"shortURL/internal/repository/repotest"
 )
 
 func TestMemoryRepository_SaveAndGet(t *testing.T) {
@@ -16,22 +18,25 @@ func TestMemoryRepository_SaveAndGet(t *testing.T) {
 	shortCode := "abc123XYZ_"
 	originalURL := "https://example.com/test"
 
-	err := repo.Save(ctx, shortCode, originalURL)
+	err := repo.Save(ctx, &repository.Link{ShortCode: shortCode, OriginalURL: originalURL})
 	if err != nil {
 		t.Fatalf("Save() failed: %v", err)
 	}
 
 	// по шортюрл
-	gotURL, err := repo.Get(ctx, shortCode)
+	gotLink, err := repo.Get(ctx, shortCode)
 	if err != nil {
 		t.Fatalf("Get() failed: %v", err)
 	}
-	if gotURL != originalURL {
-		t.Errorf("Get() = %s, want %s", gotURL, originalURL)
+	if gotLink.OriginalURL != originalURL {
+		t.Errorf("Get() = %s, want %s", gotLink.OriginalURL, originalURL)
+	}
+	if gotLink.CreatedAt.IsZero() {
+		t.Error("Get() returned zero CreatedAt")
 	}
 
 	// по оригюрл
-	gotShort, err := repo.GetByOriginal(ctx, originalURL)
+	gotShort, err := repo.GetByOriginal(ctx, "", originalURL)
 	if err != nil {
 		t.Fatalf("GetByOriginal() failed: %v", err)
 	}
@@ -51,7 +56,7 @@ func TestMemoryRepository_GetNotFound(t *testing.T) {
 	}
 
 	// попытка получить несуществующ оригюрл
-	_, err = repo.GetByOriginal(ctx, "https://notexists.com")
+	_, err = repo.GetByOriginal(ctx, "", "https://notexists.com")
 	if err != repository.ErrNotFound {
 		t.Errorf("GetByOriginal() error = %v, want %v", err, repository.ErrNotFound)
 	}
@@ -65,18 +70,18 @@ func TestMemoryRepository_SaveDuplicate(t *testing.T) {
 	shortCode2 := "xyz789ABC_"
 	originalURL := "https://example.com/test"
 
-	err := repo.Save(ctx, shortCode1, originalURL)
+	err := repo.Save(ctx, &repository.Link{ShortCode: shortCode1, OriginalURL: originalURL})
 	if err != nil {
 		t.Fatalf("Save() first failed: %v", err)
 	}
 
 	// пытаемся сохр тот же оригюрл с другим шортюрл
-	err = repo.Save(ctx, shortCode2, originalURL)
+	err = repo.Save(ctx, &repository.Link{ShortCode: shortCode2, OriginalURL: originalURL})
 	if err != repository.ErrDuplicate {
 		t.Errorf("Save() error = %v, want %v", err, repository.ErrDuplicate)
 	}
 
-	err = repo.Save(ctx, shortCode1, originalURL)
+	err = repo.Save(ctx, &repository.Link{ShortCode: shortCode1, OriginalURL: originalURL})
 	if err != nil {
 		t.Errorf("Save() same mapping failed: %v", err)
 	}
@@ -90,12 +95,12 @@ func TestMemoryRepository_SaveShortCodeExists(t *testing.T) {
 	url1 := "https://example.com/page1"
 	url2 := "https://example.com/page2"
 
-	err := repo.Save(ctx, shortCode, url1)
+	err := repo.Save(ctx, &repository.Link{ShortCode: shortCode, OriginalURL: url1})
 	if err != nil {
 		t.Fatalf("Save() first failed: %v", err)
 	}
 
-	err = repo.Save(ctx, shortCode, url2)
+	err = repo.Save(ctx, &repository.Link{ShortCode: shortCode, OriginalURL: url2})
 	if err != repository.ErrAlreadyExists {
 		t.Errorf("Save() error = %v, want %v", err, repository.ErrAlreadyExists)
 	}
@@ -115,7 +120,7 @@ func TestMemoryRepository_Concurrency(t *testing.T) {
 			defer wg.Done()
 			shortCode := fmt.Sprintf("s%08d__", id)
 			originalURL := fmt.Sprintf("https://example.com/page%d", id)
-			err := repo.Save(ctx, shortCode, originalURL)
+			err := repo.Save(ctx, &repository.Link{ShortCode: shortCode, OriginalURL: originalURL})
 			if err != nil && err != repository.ErrDuplicate && err != repository.ErrAlreadyExists {
 				errChan <- err
 			}
@@ -137,7 +142,7 @@ func TestMemoryRepository_ConcurrentReadWrite(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		shortCode := fmt.Sprintf("test%d_____", i)
 		originalURL := fmt.Sprintf("https://example.com/page%d", i)
-		_ = repo.Save(ctx, shortCode, originalURL)
+		_ = repo.Save(ctx, &repository.Link{ShortCode: shortCode, OriginalURL: originalURL})
 	}
 
 	var wg sync.WaitGroup
@@ -161,7 +166,7 @@ func TestMemoryRepository_ConcurrentReadWrite(t *testing.T) {
 			defer wg.Done()
 			shortCode := fmt.Sprintf("new%07d", id)
 			originalURL := fmt.Sprintf("https://example.com/new%d", id)
-			err := repo.Save(ctx, shortCode, originalURL)
+			err := repo.Save(ctx, &repository.Link{ShortCode: shortCode, OriginalURL: originalURL})
 			if err != nil && err != repository.ErrDuplicate && err != repository.ErrAlreadyExists {
 				errChan <- err
 			}
@@ -180,7 +185,7 @@ func TestMemoryRepository_Clear(t *testing.T) {
 	repo := NewMemoryRepository()
 	ctx := context.Background()
 
-	_ = repo.Save(ctx, "abc123XYZ_", "https://example.com/test")
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
 
 	repo.Clear()
 
@@ -189,3 +194,138 @@ func TestMemoryRepository_Clear(t *testing.T) {
 		t.Errorf("After Clear(), Get() error = %v, want %v", err, repository.ErrNotFound)
 	}
 }
+
+func TestMemoryRepository_Expiration(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "expired___", OriginalURL: "https://example.com/old", ExpiresAt: &past})
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "active____", OriginalURL: "https://example.com/new", ExpiresAt: &future})
+
+	// истекшая ссылка все еще отдается по коду, но не участвует в дедупликации
+	link, err := repo.Get(ctx, "expired___")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if !link.Expired(time.Now()) {
+		t.Error("Get() link should be expired")
+	}
+	if _, err := repo.GetByOriginal(ctx, "", "https://example.com/old"); err != repository.ErrNotFound {
+		t.Errorf("GetByOriginal() error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	// истекший код можно занять заново
+	err = repo.Save(ctx, &repository.Link{ShortCode: "expired___", OriginalURL: "https://example.com/reused"})
+	if err != nil {
+		t.Fatalf("Save() over expired link failed: %v", err)
+	}
+	if _, err := repo.GetByOriginal(ctx, "", "https://example.com/reused"); err != nil {
+		t.Errorf("GetByOriginal() after reuse failed: %v", err)
+	}
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "expired2__", OriginalURL: "https://example.com/old2", ExpiresAt: &past})
+
+	deleted, err := repo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("DeleteExpired() failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("DeleteExpired() = %d, want 1", deleted)
+	}
+
+	if _, err := repo.Get(ctx, "expired2__"); err != repository.ErrNotFound {
+		t.Errorf("Get() after DeleteExpired error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if _, err := repo.Get(ctx, "active____"); err != nil {
+		t.Errorf("Get() active link failed: %v", err)
+	}
+}
+
+func TestMemoryRepository_Delete(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+	_ = repo.SaveClicks(ctx, []repository.Click{{ShortCode: "abc123XYZ_", ClickedAt: time.Now()}})
+
+	if err := repo.Delete(ctx, "abc123XYZ_"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	if _, err := repo.Get(ctx, "abc123XYZ_"); err != repository.ErrNotFound {
+		t.Errorf("Get() after Delete() error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if _, err := repo.GetByOriginal(ctx, "", "https://example.com/test"); err != repository.ErrNotFound {
+		t.Errorf("GetByOriginal() after Delete() error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	stats, _ := repo.ClickStats(ctx, "abc123XYZ_", time.Time{})
+	if stats.Total != 0 {
+		t.Errorf("ClickStats() after Delete() total = %d, want 0", stats.Total)
+	}
+
+	if err := repo.Delete(ctx, "abc123XYZ_"); err != repository.ErrNotFound {
+		t.Errorf("Delete() twice error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	// после удаления URL можно сократить заново
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "xyz789ABC_", OriginalURL: "https://example.com/test"}); err != nil {
+		t.Errorf("Save() after Delete() failed: %v", err)
+	}
+}
+
+func TestMemoryRepository_Update(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/old"})
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "xyz789ABC_", OriginalURL: "https://example.com/taken"})
+
+	if err := repo.Update(ctx, "abc123XYZ_", "https://example.com/new"); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	link, err := repo.Get(ctx, "abc123XYZ_")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if link.OriginalURL != "https://example.com/new" {
+		t.Errorf("Get() = %s, want https://example.com/new", link.OriginalURL)
+	}
+
+	// старый URL освобождается, новый указывает на тот же код
+	if _, err := repo.GetByOriginal(ctx, "", "https://example.com/old"); err != repository.ErrNotFound {
+		t.Errorf("GetByOriginal(old) error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if code, _ := repo.GetByOriginal(ctx, "", "https://example.com/new"); code != "abc123XYZ_" {
+		t.Errorf("GetByOriginal(new) = %s, want abc123XYZ_", code)
+	}
+
+	if err := repo.Update(ctx, "abc123XYZ_", "https://example.com/taken"); err != repository.ErrDuplicate {
+		t.Errorf("Update() to taken URL error = %v, want %v", err, repository.ErrDuplicate)
+	}
+	if err := repo.Update(ctx, "notexists_", "https://example.com/x"); err != repository.ErrNotFound {
+		t.Errorf("Update() missing code error = %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func TestMemoryRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.URLRepository {
+		return NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepository_Keys(t *testing.T) {
+	repotest.RunKeys(t, func(t *testing.T) repository.KeyRepository {
+		return NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepository_Sequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) repository.SequenceRepository {
+		return NewMemoryRepository()
+	})
+}
@@ -0,0 +1,539 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"shortURL/internal/repository"
+)
+
+// FsyncPolicy когда сбрасывать журнал на диск
+type FsyncPolicy string
+
+const (
+	// FsyncAlways после каждой записи в журнал
+	FsyncAlways FsyncPolicy = "always"
+
+	// FsyncInterval раз в секунду, при падении теряется не больше секунды записей
+	FsyncInterval FsyncPolicy = "interval"
+
+	// FsyncNever полагаемся на ОС
+	FsyncNever FsyncPolicy = "never"
+)
+
+// PersistConfig настройки сохранения ссылок на диск.
+// Рядом со снапшотом лежит журнал операций с суффиксом .log,
+// переходы по ссылкам на диск не сохраняются
+type PersistConfig struct {
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	Fsync            FsyncPolicy
+}
+
+// ValidFsyncPolicy известна ли политика fsync
+func ValidFsyncPolicy(p FsyncPolicy) bool {
+	return p == FsyncAlways || p == FsyncInterval || p == FsyncNever
+}
+
+const (
+	opSave   = "save"
+	opDelete = "delete"
+	opUpdate = "update"
+	opClear  = "clear"
+
+	opSaveKey   = "save_key"
+	opRevokeKey = "revoke_key"
+
+	opSequence = "sequence"
+)
+
+// logEntry строка журнала
+type logEntry struct {
+	Op   string      `json:"op"`
+	Link *linkRecord `json:"link,omitempty"`
+	Code string      `json:"code,omitempty"`
+	URL  string      `json:"url,omitempty"`
+
+	Key *keyRecord `json:"key,omitempty"`
+	At  *time.Time `json:"at,omitempty"`
+
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+// linkRecord ссылка в снапшоте и журнале
+type linkRecord struct {
+	ID          int64      `json:"id"`
+	ShortCode   string     `json:"short_code"`
+	OriginalURL string     `json:"original_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Owner       string     `json:"owner,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	RedirectStatus int `json:"redirect_status,omitempty"`
+}
+
+func newLinkRecord(link *repository.Link) *linkRecord {
+	return &linkRecord{
+		ID:          link.ID,
+		ShortCode:   link.ShortCode,
+		OriginalURL: link.OriginalURL,
+		CreatedAt:   link.CreatedAt,
+		Owner:       link.Owner,
+		ExpiresAt:   link.ExpiresAt,
+
+		RedirectStatus: link.RedirectStatus,
+	}
+}
+
+func (rec *linkRecord) link() *repository.Link {
+	return &repository.Link{
+		ID:          rec.ID,
+		ShortCode:   rec.ShortCode,
+		OriginalURL: rec.OriginalURL,
+		CreatedAt:   rec.CreatedAt,
+		Owner:       rec.Owner,
+		ExpiresAt:   rec.ExpiresAt,
+
+		RedirectStatus: rec.RedirectStatus,
+	}
+}
+
+// keyRecord API ключ в снапшоте и журнале
+type keyRecord struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	SecretHash string     `json:"secret_hash"`
+	Admin      bool       `json:"admin,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func newKeyRecord(key *repository.APIKey) *keyRecord {
+	rec := keyRecord(*key)
+	return &rec
+}
+
+func (rec *keyRecord) key() *repository.APIKey {
+	key := repository.APIKey(*rec)
+	return &key
+}
+
+// snapshot содержимое файла снапшота
+type snapshot struct {
+	LastID int64         `json:"last_id"`
+	Seq    uint64        `json:"seq,omitempty"`
+	Links  []*linkRecord `json:"links"`
+	Keys   []*keyRecord  `json:"keys,omitempty"`
+}
+
+// persister журнал и снапшоты одного MemoryRepository
+type persister struct {
+	cfg     PersistConfig
+	logFile *os.File
+
+	// snapshotMu не дает двум снапшотам писаться одновременно
+	snapshotMu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (p *persister) logPath() string     { return p.cfg.SnapshotPath + ".log" }
+func (p *persister) rotatedPath() string { return p.cfg.SnapshotPath + ".log.old" }
+
+// NewPersistentMemoryRepository восстанавливает ссылки из снапшота и журнала
+// и дальше сохраняет все изменения на диск
+func NewPersistentMemoryRepository(cfg PersistConfig) (*MemoryRepository, error) {
+	if cfg.SnapshotPath == "" {
+		return nil, errors.New("snapshot path is required")
+	}
+	if cfg.SnapshotInterval <= 0 {
+		return nil, errors.New("snapshot interval must be positive")
+	}
+	if !ValidFsyncPolicy(cfg.Fsync) {
+		return nil, fmt.Errorf("unknown fsync policy: %s", cfg.Fsync)
+	}
+
+	r := NewMemoryRepository()
+	p := &persister{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if err := r.restore(p); err != nil {
+		return nil, err
+	}
+
+	logFile, err := os.OpenFile(p.logPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	p.logFile = logFile
+	r.persist = p
+
+	go r.runPersister()
+
+	return r, nil
+}
+
+// restore читает снапшот, затем журнал, оставшийся от прерванного снапшота, затем текущий журнал
+func (r *MemoryRepository) restore(p *persister) error {
+	data, err := os.ReadFile(p.cfg.SnapshotPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+		for _, rec := range snap.Links {
+			r.applySave(rec.link())
+		}
+		for _, rec := range snap.Keys {
+			r.keys[rec.ID] = rec.key()
+		}
+		if snap.LastID > r.lastID {
+			r.lastID = snap.LastID
+		}
+		r.seq = max(r.seq, snap.Seq)
+	}
+
+	for _, path := range []string{p.rotatedPath(), p.logPath()} {
+		if err := r.replay(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replay применяет операции из журнала, операции идемпотентны. Недописанную
+// при падении последнюю строку отрезает, иначе следующая запись склеится с ней
+func (r *MemoryRepository) replay(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+
+	// offset конец последней примененной строки
+	var offset int64
+	terminated := true
+	var pending error
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+		if len(data) == 0 {
+			break
+		}
+		if pending != nil {
+			// битой может быть только последняя строка, недописанная при падении
+			return pending
+		}
+
+		var entry logEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			pending = fmt.Errorf("failed to decode %s line %d: %w", path, line, err)
+			continue
+		}
+		r.apply(entry)
+		offset += int64(len(data))
+		terminated = data[len(data)-1] == '\n'
+	}
+
+	if pending != nil {
+		slog.Warn("truncating torn last line of log", "path", path, "error", pending)
+		if err := f.Truncate(offset); err != nil {
+			return fmt.Errorf("failed to truncate log: %w", err)
+		}
+	}
+	if !terminated {
+		// строка целая, но перевод строки записаться не успел
+		if _, err := f.WriteAt([]byte{'\n'}, offset); err != nil {
+			return fmt.Errorf("failed to repair log: %w", err)
+		}
+	}
+	if pending != nil || !terminated {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("failed to sync log: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) apply(entry logEntry) {
+	switch entry.Op {
+	case opSave:
+		if entry.Link != nil {
+			r.applySave(entry.Link.link())
+		}
+	case opDelete:
+		r.remove(entry.Code)
+	case opUpdate:
+		r.applyUpdate(entry.Code, entry.URL)
+	case opClear:
+		r.links = make(map[string]*repository.Link)
+		r.originalToShort = make(map[string]string)
+	case opSaveKey:
+		if entry.Key != nil {
+			r.keys[entry.Key.ID] = entry.Key.key()
+		}
+	case opRevokeKey:
+		if entry.At != nil {
+			r.applyRevokeKey(entry.Code, *entry.At)
+		}
+	case opSequence:
+		r.seq = max(r.seq, entry.Seq)
+	}
+}
+
+// writeLog дописывает операцию в журнал, вызывается под блокировкой до изменения мап
+func (r *MemoryRepository) writeLog(entry logEntry) error {
+	if r.persist == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if _, err := r.persist.logFile.Write(data); err != nil {
+		return fmt.Errorf("failed to write log: %w", err)
+	}
+	if r.persist.cfg.Fsync == FsyncAlways {
+		if err := r.persist.logFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync log: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) runPersister() {
+	p := r.persist
+	defer close(p.done)
+
+	snapshotTicker := time.NewTicker(p.cfg.SnapshotInterval)
+	defer snapshotTicker.Stop()
+
+	syncTicker := time.NewTicker(time.Second)
+	defer syncTicker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-snapshotTicker.C:
+			if err := r.Snapshot(); err != nil {
+				slog.Error("failed to write snapshot", "error", err)
+			}
+		case <-syncTicker.C:
+			if p.cfg.Fsync == FsyncInterval {
+				r.mu.RLock()
+				err := p.logFile.Sync()
+				r.mu.RUnlock()
+				if err != nil {
+					slog.Error("failed to sync log", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// Snapshot атомарно записывает все ссылки в файл снапшота и обнуляет журнал.
+// Под блокировкой только копируется состояние и подменяется файл журнала,
+// сам снапшот пишется во временный файл и переименовывается
+func (r *MemoryRepository) Snapshot() error {
+	p := r.persist
+	if p == nil {
+		return nil
+	}
+
+	p.snapshotMu.Lock()
+	defer p.snapshotMu.Unlock()
+
+	r.mu.Lock()
+	snap := snapshot{
+		LastID: r.lastID,
+		Seq:    r.seq,
+		Links:  make([]*linkRecord, 0, len(r.links)),
+	}
+	for _, link := range r.links {
+		snap.Links = append(snap.Links, newLinkRecord(link))
+	}
+	for _, key := range r.keys {
+		snap.Keys = append(snap.Keys, newKeyRecord(key))
+	}
+
+	// операции после этого момента пойдут в новый журнал
+	err := r.rotateLog()
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(p.cfg.SnapshotPath, snap); err != nil {
+		return err
+	}
+
+	// старый журнал уже целиком в снапшоте
+	if err := os.Remove(p.rotatedPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove rotated log: %w", err)
+	}
+
+	return nil
+}
+
+// rotateLog переименовывает журнал и открывает новый, вызывается под блокировкой.
+// Старый файл закрывается последним: пока новый не открыт, записи идут в старый
+// дескриптор, и сбой на любом шаге не оставляет репозиторий без журнала
+func (r *MemoryRepository) rotateLog() error {
+	p := r.persist
+
+	if err := p.logFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+
+	// если прошлый снапшот не дописался, его журнал еще не удален и нужен при восстановлении
+	merged := false
+	if _, err := os.Stat(p.rotatedPath()); err == nil {
+		if err := appendFile(p.rotatedPath(), p.logPath()); err != nil {
+			return err
+		}
+		if err := os.Remove(p.logPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove log: %w", err)
+		}
+		merged = true
+	} else if err := os.Rename(p.logPath(), p.rotatedPath()); err != nil {
+		return fmt.Errorf("failed to rotate log: %w", err)
+	}
+
+	logFile, err := os.OpenFile(p.logPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		err = fmt.Errorf("failed to open log: %w", err)
+		// после переименования старый дескриптор и так пишет в старый журнал,
+		// а удаленный после слияния файл приходится открыть заново. Старый
+		// журнал при восстановлении читается первым, так что порядок сохранится
+		if merged {
+			rotated, openErr := os.OpenFile(p.rotatedPath(), os.O_WRONLY|os.O_APPEND, 0600)
+			if openErr != nil {
+				return errors.Join(err, fmt.Errorf("failed to reopen rotated log: %w", openErr))
+			}
+			p.logFile.Close()
+			p.logFile = rotated
+		}
+		return err
+	}
+
+	old := p.logFile
+	p.logFile = logFile
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("failed to close log: %w", err)
+	}
+
+	return nil
+}
+
+// closePersister останавливает фоновые задачи, пишет финальный снапшот и закрывает журнал
+func (r *MemoryRepository) closePersister() error {
+	p := r.persist
+	if p == nil {
+		return nil
+	}
+
+	close(p.stop)
+	<-p.done
+
+	snapErr := r.Snapshot()
+
+	r.mu.Lock()
+	closeErr := p.logFile.Close()
+	r.persist = nil
+	r.mu.Unlock()
+
+	if snapErr != nil {
+		return snapErr
+	}
+	return closeErr
+}
+
+// writeFileAtomic пишет JSON во временный файл рядом с path и переименовывает его
+func writeFileAtomic(path string, v interface{}) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+
+	// фиксируем переименование
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// appendFile дописывает содержимое src в конец dst
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		// журнал уже слит в старый, если прошлая ротация не смогла открыть новый
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open rotated log: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to merge logs: %w", err)
+	}
+
+	return out.Sync()
+}
@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shortURL/internal/repository"
+	"shortURL/internal/repository/repotest"
+)
+
+func newPersistentRepository(t *testing.T, path string) *MemoryRepository {
+	t.Helper()
+
+	repo, err := NewPersistentMemoryRepository(PersistConfig{
+		SnapshotPath:     path,
+		SnapshotInterval: time.Hour,
+		Fsync:            FsyncAlways,
+	})
+	if err != nil {
+		t.Fatalf("NewPersistentMemoryRepository() failed: %v", err)
+	}
+
+	return repo
+}
+
+func TestPersistentMemoryRepository_Restore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.snapshot")
+	ctx := context.Background()
+
+	repo := newPersistentRepository(t, path)
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "keep______", OriginalURL: "https://example.com/keep", Owner: "key1"})
+	_ = repo.SaveKey(ctx, &repository.APIKey{ID: "key1", SecretHash: "hash1"})
+	_ = repo.SaveKey(ctx, &repository.APIKey{ID: "key2", SecretHash: "hash2"})
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "deleted___", OriginalURL: "https://example.com/deleted"})
+
+	// часть изменений попадает в снапшот, часть только в журнал
+	if err := repo.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "moved_____", OriginalURL: "https://example.com/old"})
+	_ = repo.Update(ctx, "moved_____", "https://example.com/new")
+	_ = repo.Delete(ctx, "deleted___")
+	_ = repo.RevokeKey(ctx, "key2", time.Now())
+
+	// имитируем падение: журнал не закрыт, финального снапшота нет
+	close(repo.persist.stop)
+	<-repo.persist.done
+	repo.persist.logFile.Close()
+
+	restored := newPersistentRepository(t, path)
+	defer restored.Close()
+
+	if link, err := restored.Get(ctx, "keep______"); err != nil || link.OriginalURL != "https://example.com/keep" || link.Owner != "key1" {
+		t.Errorf("Get(keep) = %v, %v", link, err)
+	}
+	if key, err := restored.GetKey(ctx, "key1"); err != nil || key.SecretHash != "hash1" || key.Revoked() {
+		t.Errorf("GetKey(key1) = %v, %v", key, err)
+	}
+	if key, err := restored.GetKey(ctx, "key2"); err != nil || !key.Revoked() {
+		t.Errorf("GetKey(key2) = %v, %v, want revoked", key, err)
+	}
+	if link, err := restored.Get(ctx, "moved_____"); err != nil || link.OriginalURL != "https://example.com/new" {
+		t.Errorf("Get(moved) = %v, %v", link, err)
+	}
+	if _, err := restored.GetByOriginal(ctx, "", "https://example.com/old"); err != repository.ErrNotFound {
+		t.Errorf("GetByOriginal(old) error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if _, err := restored.Get(ctx, "deleted___"); err != repository.ErrNotFound {
+		t.Errorf("Get(deleted) error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	// id продолжают последовательность
+	link := &repository.Link{ShortCode: "next______", OriginalURL: "https://example.com/next"}
+	_ = restored.Save(ctx, link)
+	if link.ID != 4 {
+		t.Errorf("Save() after restore ID = %d, want 4", link.ID)
+	}
+}
+
+func TestPersistentMemoryRepository_CloseWritesSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.snapshot")
+	ctx := context.Background()
+
+	repo := newPersistentRepository(t, path)
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if info, err := os.Stat(path + ".log"); err != nil || info.Size() != 0 {
+		t.Errorf("log after Close() = %v, %v, want empty file", info, err)
+	}
+
+	restored := newPersistentRepository(t, path)
+	defer restored.Close()
+
+	if _, err := restored.Get(ctx, "abc123XYZ_"); err != nil {
+		t.Errorf("Get() after restore failed: %v", err)
+	}
+}
+
+func TestPersistentMemoryRepository_TruncatedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.snapshot")
+	ctx := context.Background()
+
+	repo := newPersistentRepository(t, path)
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+	close(repo.persist.stop)
+	<-repo.persist.done
+	repo.persist.logFile.Close()
+
+	// запись оборвалась на середине строки
+	f, _ := os.OpenFile(path+".log", os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"op":"save","link":{"id":2,`)
+	f.Close()
+
+	restored := newPersistentRepository(t, path)
+	defer restored.Close()
+
+	if _, err := restored.Get(ctx, "abc123XYZ_"); err != nil {
+		t.Errorf("Get() after truncated log failed: %v", err)
+	}
+}
+
+func TestPersistentMemoryRepository_WriteAfterTornLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.snapshot")
+	ctx := context.Background()
+
+	for _, torn := range []string{`{"op":"save","link":{"id":2,`, `{"op":"delete","code":"nothing___"}`} {
+		repo := newPersistentRepository(t, path)
+		_ = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"})
+		close(repo.persist.stop)
+		<-repo.persist.done
+		repo.persist.logFile.Close()
+
+		// строка оборвана: в середине или прямо перед переводом строки
+		f, _ := os.OpenFile(path+".log", os.O_WRONLY|os.O_APPEND, 0600)
+		f.WriteString(torn)
+		f.Close()
+
+		// после восстановления запись идет с новой строки
+		restored := newPersistentRepository(t, path)
+		_ = restored.Save(ctx, &repository.Link{ShortCode: "after_____", OriginalURL: "https://example.com/after"})
+		close(restored.persist.stop)
+		<-restored.persist.done
+		restored.persist.logFile.Close()
+
+		again := newPersistentRepository(t, path)
+		for _, code := range []string{"abc123XYZ_", "after_____"} {
+			if _, err := again.Get(ctx, code); err != nil {
+				t.Errorf("Get(%s) after torn %q failed: %v", code, torn, err)
+			}
+		}
+		again.Close()
+		os.Remove(path)
+		os.Remove(path + ".log")
+	}
+}
+
+func TestPersistentMemoryRepository_Sequence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.snapshot")
+	ctx := context.Background()
+
+	repo := newPersistentRepository(t, path)
+	for range 5 {
+		_, _ = repo.NextSequence(ctx)
+	}
+	// счетчик попадает в снапшот
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	restored := newPersistentRepository(t, path)
+	_, _ = restored.NextSequence(ctx)
+	// а выданный после снапшота номер только в журнал
+	close(restored.persist.stop)
+	<-restored.persist.done
+	restored.persist.logFile.Close()
+
+	again := newPersistentRepository(t, path)
+	defer again.Close()
+
+	if n, err := again.NextSequence(ctx); err != nil || n != 7 {
+		t.Errorf("NextSequence() after restore = %d, %v, want 7", n, err)
+	}
+}
+
+func TestPersistentMemoryRepository_FailedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.snapshot")
+	ctx := context.Background()
+
+	repo := newPersistentRepository(t, path)
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "before____", OriginalURL: "https://example.com/before"})
+
+	// на месте старого журнала каталог, слить в него журнал не выйдет
+	if err := os.Mkdir(path+".log.old", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Snapshot(); err == nil {
+		t.Fatal("Snapshot() error = nil, want rotation failure")
+	}
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "after_____", OriginalURL: "https://example.com/after"}); err != nil {
+		t.Fatalf("Save() after failed rotation: %v", err)
+	}
+
+	close(repo.persist.stop)
+	<-repo.persist.done
+	repo.persist.logFile.Close()
+	os.Remove(path + ".log.old")
+
+	restored := newPersistentRepository(t, path)
+	defer restored.Close()
+
+	for _, code := range []string{"before____", "after_____"} {
+		if _, err := restored.Get(ctx, code); err != nil {
+			t.Errorf("Get(%s) after restore failed: %v", code, err)
+		}
+	}
+}
+
+func TestPersistentMemoryRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.URLRepository {
+		repo := newPersistentRepository(t, filepath.Join(t.TempDir(), "links.snapshot"))
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestNewPersistentMemoryRepository_InvalidConfig(t *testing.T) {
+	invalid := []PersistConfig{
+		{SnapshotInterval: time.Hour, Fsync: FsyncAlways},
+		{SnapshotPath: "x", Fsync: FsyncAlways},
+		{SnapshotPath: "x", SnapshotInterval: time.Hour, Fsync: "sometimes"},
+	}
+	for _, cfg := range invalid {
+		if _, err := NewPersistentMemoryRepository(cfg); err == nil {
+			t.Errorf("NewPersistentMemoryRepository(%+v) expected error, got nil", cfg)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"shortURL/internal/repository"
+)
+
+// keyColumns колонки, которые читает scanKey
+const keyColumns = `id, name, secret_hash, admin, created_at, revoked_at`
+
+// SaveKey сохраняет новый API ключ
+func (r *PostgresRepository) SaveKey(ctx context.Context, key *repository.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, name, secret_hash, admin, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, key.ID, key.Name, key.SecretHash, key.Admin).Scan(&key.CreatedAt)
+	if err != nil {
+		if _, ok := uniqueConstraint(err); ok {
+			return repository.ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	return nil
+}
+
+// GetKey получает API ключ по id
+func (r *PostgresRepository) GetKey(ctx context.Context, id string) (*repository.APIKey, error) {
+	query := `SELECT ` + keyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := scanKey(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListKeys возвращает ключи от старых к новым
+func (r *PostgresRepository) ListKeys(ctx context.Context) ([]*repository.APIKey, error) {
+	query := `SELECT ` + keyColumns + ` FROM api_keys ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*repository.APIKey
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeKey отзывает API ключ, время первого отзыва сохраняется
+func (r *PostgresRepository) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+// scanKey читает ключ из строки выборки keyColumns
+func scanKey(row rowScanner) (*repository.APIKey, error) {
+	var key repository.APIKey
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.Name, &key.SecretHash, &key.Admin, &key.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"shortURL/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"README.md":           {Data: []byte("ignored")},
+	}
+
+	list, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations() failed: %v", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("LoadMigrations() returned %d migrations, want 2", len(list))
+	}
+	if list[0].Version != 1 || list[0].Name != "first" || list[0].Down != "" {
+		t.Errorf("first migration = %+v", list[0])
+	}
+	if list[1].Version != 2 || list[1].Up == "" || list[1].Down == "" {
+		t.Errorf("second migration = %+v", list[1])
+	}
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "down without up",
+			fsys: fstest.MapFS{
+				"001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+		},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"001_first.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+				"001_other.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadMigrations(tt.fsys); err == nil {
+				t.Error("LoadMigrations() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("LoadMigrations() failed: %v", err)
+	}
+
+	for i, migration := range list {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d_%s has version gap, want %d", migration.Version, migration.Name, i+1)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+	}
+}
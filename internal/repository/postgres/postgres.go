@@ -5,11 +5,23 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"shortURL/internal/repository"
 )
 
+const (
+	// uniqueViolation код ошибки postgres при нарушении уникальности
+	uniqueViolation = "23505"
+
+	// имена unique constraint таблицы urls: код уникален глобально, URL в
+	// пределах владельца (migrations/006)
+	shortCodeConstraint   = "urls_short_code_key"
+	originalURLConstraint = "urls_owner_original_url_key"
+)
+
 type PostgresRepository struct {
 	db *sql.DB
 }
@@ -29,65 +41,365 @@ func NewPostgresRepository(connString string) (*PostgresRepository, error) {
 	return &PostgresRepository{db: db}, nil
 }
 
-// Save сохранияет новый shortURL
-func (r *PostgresRepository) Save(ctx context.Context, shortCode string, originalURL string) error {
+// Save сохранияет новый shortURL.
+// Вставка и разбор конфликта идут в одной транзакции: при нарушении уникальности
+// откатываемся к savepoint и по имени constraint понимаем, что именно занято
+func (r *PostgresRepository) Save(ctx context.Context, link *repository.Link) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// истекшие ссылки с тем же кодом или URL владельца освобождают место
+	purgeQuery := `
+		WITH expired AS (
+			DELETE FROM urls
+			WHERE (short_code = $1 OR (owner = $3 AND original_url = $2))
+				AND expires_at IS NOT NULL AND expires_at <= NOW()
+			RETURNING short_code
+		)
+		DELETE FROM clicks WHERE short_code IN (SELECT short_code FROM expired)
+	`
+	if _, err := tx.ExecContext(ctx, purgeQuery, link.ShortCode, link.OriginalURL, link.Owner); err != nil {
+		return fmt.Errorf("failed to purge expired URL: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT save_url`); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
 	query := `
-		INSERT INTO urls (short_code, original_url, created_at)
-		VALUES ($1, $2, NOW())
-		ON CONFLICT (short_code) DO NOTHING
+		INSERT INTO urls (short_code, original_url, created_at, expires_at, owner, redirect_status)
+		VALUES ($1, $2, NOW(), $3, $4, $5)
+		RETURNING id, created_at
 	`
 
-	result, err := r.db.ExecContext(ctx, query, shortCode, originalURL)
+	var id int64
+	var createdAt time.Time
+	err = tx.QueryRowContext(ctx, query, link.ShortCode, link.OriginalURL, link.ExpiresAt, link.Owner, link.RedirectStatus).Scan(&id, &createdAt)
 	if err != nil {
-		// проверяем уникальность ориг юрлаа
-		if errors.Is(err, sql.ErrNoRows) {
-			return repository.ErrDuplicate
+		constraint, ok := uniqueConstraint(err)
+		if !ok {
+			return fmt.Errorf("failed to save URL: %w", err)
 		}
-		return fmt.Errorf("failed to save URL: %w", err)
+
+		if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT save_url`); err != nil {
+			return fmt.Errorf("failed to rollback to savepoint: %w", err)
+		}
+		return resolveConflict(ctx, tx, constraint, link)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit URL: %w", err)
+	}
+
+	link.ID = id
+	link.CreatedAt = createdAt
+	return nil
+}
+
+// resolveConflict разбирает нарушение уникальности при вставке link.
+// Та же пара код-URL того же владельца уже сохранена - не ошибка
+func resolveConflict(ctx context.Context, tx *sql.Tx, constraint string, link *repository.Link) error {
+	switch constraint {
+	case shortCodeConstraint:
+		var existingURL, existingOwner string
+		err := tx.QueryRowContext(ctx, `SELECT original_url, owner FROM urls WHERE short_code = $1`, link.ShortCode).Scan(&existingURL, &existingOwner)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check existing URL: %w", err)
+		}
+		if existingURL == link.OriginalURL && existingOwner == link.Owner {
+			return nil
+		}
+		return repository.ErrAlreadyExists
+
+	case originalURLConstraint:
+		var existingShort string
+		err := tx.QueryRowContext(ctx, `SELECT short_code FROM urls WHERE owner = $1 AND original_url = $2`, link.Owner, link.OriginalURL).Scan(&existingShort)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check existing short code: %w", err)
+		}
+		if existingShort == link.ShortCode {
+			return nil
+		}
+		return repository.ErrDuplicate
+
+	default:
+		return fmt.Errorf("unexpected unique violation on %s", constraint)
+	}
+}
+
+// maxBatchRows столько строк вставляем одним INSERT, чтобы не упереться
+// в лимит 65535 параметров запроса
+const maxBatchRows = 1000
+
+// SaveMany сохраняет ссылки в одной транзакции многострочными INSERT.
+// Конфликты не прерывают вставку (ON CONFLICT DO NOTHING), а разбираются
+// после нее по тому, что лежит в таблице
+func (r *PostgresRepository) SaveMany(ctx context.Context, links []*repository.Link) ([]error, error) {
+	errs := make([]error, len(links))
+	if len(links) == 0 {
+		return errs, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		// shortURL уже есть смотрим на его оригURl
-		existingURL, err := r.Get(ctx, shortCode)
+	codes, urls, owners := linkColumnsOf(links)
+
+	purgeQuery := `
+		WITH expired AS (
+			DELETE FROM urls
+			WHERE (short_code = ANY($1) OR (owner, original_url) IN (SELECT * FROM unnest($3::text[], $2::text[])))
+				AND expires_at IS NOT NULL AND expires_at <= NOW()
+			RETURNING short_code
+		)
+		DELETE FROM clicks WHERE short_code IN (SELECT short_code FROM expired)
+	`
+	if _, err := tx.ExecContext(ctx, purgeQuery, pq.Array(codes), pq.Array(urls), pq.Array(owners)); err != nil {
+		return nil, fmt.Errorf("failed to purge expired URLs: %w", err)
+	}
+
+	var conflicts []int
+	for start := 0; start < len(links); start += maxBatchRows {
+		chunk := links[start:min(start+maxBatchRows, len(links))]
+
+		inserted, err := insertLinks(ctx, tx, chunk)
 		if err != nil {
-			return fmt.Errorf("failed to check existing URL: %w", err)
+			return nil, err
 		}
-		if existingURL != originalURL {
-			return repository.ErrAlreadyExists
+		for i, link := range chunk {
+			row, ok := inserted[link.ShortCode]
+			if !ok || row.OriginalURL != link.OriginalURL || row.Owner != link.Owner {
+				conflicts = append(conflicts, start+i)
+				continue
+			}
+			link.ID = row.ID
+			link.CreatedAt = row.CreatedAt
+		}
+	}
+
+	if len(conflicts) > 0 {
+		if err := resolveConflicts(ctx, tx, links, conflicts, errs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit URLs: %w", err)
+	}
+
+	return errs, nil
+}
+
+// insertLinks вставляет ссылки одним запросом и возвращает вставленные по коду
+func insertLinks(ctx context.Context, tx *sql.Tx, links []*repository.Link) (map[string]*repository.Link, error) {
+	var query strings.Builder
+	query.WriteString(`INSERT INTO urls (short_code, original_url, created_at, expires_at, owner, redirect_status) VALUES `)
+
+	args := make([]interface{}, 0, len(links)*5)
+	for i, link := range links {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, NOW(), $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5)
+		args = append(args, link.ShortCode, link.OriginalURL, link.ExpiresAt, link.Owner, link.RedirectStatus)
+	}
+	query.WriteString(` ON CONFLICT DO NOTHING RETURNING short_code, original_url, owner, id, created_at`)
+
+	rows, err := tx.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save URLs: %w", err)
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]*repository.Link, len(links))
+	for rows.Next() {
+		link := &repository.Link{}
+		if err := rows.Scan(&link.ShortCode, &link.OriginalURL, &link.Owner, &link.ID, &link.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saved URL: %w", err)
+		}
+		inserted[link.ShortCode] = link
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to save URLs: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// resolveConflicts заполняет errs для невставленных ссылок так же, как
+// resolveConflict для одной: та же пара код-URL того же владельца уже
+// сохранена - не ошибка
+func resolveConflicts(ctx context.Context, tx *sql.Tx, links []*repository.Link, conflicts []int, errs []error) error {
+	conflicting := make([]*repository.Link, len(conflicts))
+	for i, idx := range conflicts {
+		conflicting[i] = links[idx]
+	}
+	codes, urls, owners := linkColumnsOf(conflicting)
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT short_code, original_url, owner FROM urls
+		WHERE short_code = ANY($1) OR (owner, original_url) IN (SELECT * FROM unnest($3::text[], $2::text[]))
+	`, pq.Array(codes), pq.Array(urls), pq.Array(owners))
+	if err != nil {
+		return fmt.Errorf("failed to check existing URLs: %w", err)
+	}
+	defer rows.Close()
+
+	byCode := make(map[string]string)
+	byURL := make(map[string]string)
+	for rows.Next() {
+		var code, url, owner string
+		if err := rows.Scan(&code, &url, &owner); err != nil {
+			return fmt.Errorf("failed to scan existing URL: %w", err)
+		}
+		key := repository.OriginalKey(owner, url)
+		byCode[code] = key
+		byURL[key] = code
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check existing URLs: %w", err)
+	}
+
+	for _, idx := range conflicts {
+		link := links[idx]
+		key := repository.OriginalKey(link.Owner, link.OriginalURL)
+		existingKey, codeTaken := byCode[link.ShortCode]
+		switch {
+		case codeTaken && existingKey == key:
+			errs[idx] = nil
+		case codeTaken:
+			errs[idx] = repository.ErrAlreadyExists
+		case byURL[key] != "":
+			errs[idx] = repository.ErrDuplicate
+		default:
+			// конфликтующую строку успели удалить, пусть вызывающий повторит
+			errs[idx] = repository.ErrAlreadyExists
 		}
-		return nil
 	}
 
 	return nil
 }
 
-// Get получает оригинальный url по shortURL
-func (r *PostgresRepository) Get(ctx context.Context, shortCode string) (string, error) {
-	query := `SELECT original_url FROM urls WHERE short_code = $1`
+// linkColumnsOf раскладывает ссылки по столбцам для запросов с ANY и unnest
+func linkColumnsOf(links []*repository.Link) (codes, urls, owners []string) {
+	codes = make([]string, len(links))
+	urls = make([]string, len(links))
+	owners = make([]string, len(links))
+	for i, link := range links {
+		codes[i], urls[i], owners[i] = link.ShortCode, link.OriginalURL, link.Owner
+	}
+	return codes, urls, owners
+}
+
+// uniqueConstraint возвращает имя constraint, если err это нарушение уникальности
+func uniqueConstraint(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return pqErr.Constraint, true
+	}
+	return "", false
+}
+
+// Get получает ссылку по shortURL
+func (r *PostgresRepository) Get(ctx context.Context, shortCode string) (*repository.Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM urls WHERE short_code = $1`
 
-	var originalURL string
-	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(&originalURL)
+	link, err := scanLink(r.db.QueryRowContext(ctx, query, shortCode))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", repository.ErrNotFound
+			return nil, repository.ErrNotFound
 		}
-		return "", fmt.Errorf("failed to get URL: %w", err)
+		return nil, fmt.Errorf("failed to get URL: %w", err)
 	}
 
-	return originalURL, nil
+	return link, nil
 }
 
-// GetByOriginal получает shortURL по оригу
-func (r *PostgresRepository) GetByOriginal(ctx context.Context, originalURL string) (string, error) {
-	query := `SELECT short_code FROM urls WHERE original_url = $1`
+// List возвращает страницу ссылок от новых к старым
+func (r *PostgresRepository) List(ctx context.Context, filter repository.ListFilter, cursor *repository.Cursor, limit int) (*repository.LinkPage, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(cursor.CreatedAt), arg(cursor.ID)))
+	}
+	if filter.Query != "" {
+		conditions = append(conditions, fmt.Sprintf(`original_url ILIKE '%%' || %s || '%%'`, arg(escapeLike(filter.Query))))
+	}
+	if filter.Owner != "" {
+		conditions = append(conditions, fmt.Sprintf("owner = %s", arg(filter.Owner)))
+	}
+	if filter.Host != "" {
+		conditions = append(conditions, fmt.Sprintf("lower(substring(original_url from '^[^:]+://(?:[^/?#@]*@)?([^/?#:]+)')) = lower(%s)", arg(filter.Host)))
+	}
+
+	query := `SELECT ` + linkColumns + ` FROM urls`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	// берем на одну запись больше, чтобы понять есть ли следующая страница
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ` + arg(limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list URLs: %w", err)
+	}
+	defer rows.Close()
+
+	page := &repository.LinkPage{}
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		page.Links = append(page.Links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URLs: %w", err)
+	}
+
+	if len(page.Links) > limit {
+		page.Links = page.Links[:limit]
+		page.Next = repository.CursorAfter(page.Links[limit-1])
+	}
+
+	return page, nil
+}
+
+// NextSequence выдает следующий номер из short_code_seq
+func (r *PostgresRepository) NextSequence(ctx context.Context) (uint64, error) {
+	var n int64
+	if err := r.db.QueryRowContext(ctx, `SELECT nextval('short_code_seq')`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to get next sequence: %w", err)
+	}
+
+	return uint64(n), nil
+}
+
+// GetByOriginal получает shortURL владельца по оригу
+func (r *PostgresRepository) GetByOriginal(ctx context.Context, owner, originalURL string) (string, error) {
+	query := `
+		SELECT short_code FROM urls
+		WHERE owner = $1 AND original_url = $2 AND (expires_at IS NULL OR expires_at > NOW())
+	`
 
 	var shortCode string
-	err := r.db.QueryRowContext(ctx, query, originalURL).Scan(&shortCode)
+	err := r.db.QueryRowContext(ctx, query, owner, originalURL).Scan(&shortCode)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", repository.ErrNotFound
@@ -98,28 +410,210 @@ func (r *PostgresRepository) GetByOriginal(ctx context.Context, originalURL stri
 	return shortCode, nil
 }
 
-func (r *PostgresRepository) Close() error {
-	return r.db.Close()
+// Delete удаляет ссылку и ее переходы
+func (r *PostgresRepository) Delete(ctx context.Context, shortCode string) error {
+	query := `
+		WITH deleted AS (
+			DELETE FROM urls WHERE short_code = $1 RETURNING short_code
+		), purged AS (
+			DELETE FROM clicks WHERE short_code IN (SELECT short_code FROM deleted)
+		)
+		SELECT COUNT(*) FROM deleted
+	`
+
+	var deleted int64
+	if err := r.db.QueryRowContext(ctx, query, shortCode).Scan(&deleted); err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+	if deleted == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
 }
 
-// InitSchema схема бд
-func (r *PostgresRepository) InitSchema(ctx context.Context) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS urls (
-			id SERIAL PRIMARY KEY,
-			short_code VARCHAR(10) UNIQUE NOT NULL,
-			original_url TEXT UNIQUE NOT NULL,
-			created_at TIMESTAMP DEFAULT NOW()
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_short_code ON urls(short_code);
-		CREATE INDEX IF NOT EXISTS idx_original_url ON urls(original_url);
+// Update перенаправляет shortURL на новый URL
+func (r *PostgresRepository) Update(ctx context.Context, shortCode string, newURL string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// истекшая ссылка того же владельца на новый URL не мешает перенаправлению
+	purgeQuery := `
+		WITH expired AS (
+			DELETE FROM urls
+			WHERE original_url = $2 AND short_code <> $1
+				AND owner = (SELECT owner FROM urls WHERE short_code = $1)
+				AND expires_at IS NOT NULL AND expires_at <= NOW()
+			RETURNING short_code
+		)
+		DELETE FROM clicks WHERE short_code IN (SELECT short_code FROM expired)
 	`
+	if _, err := tx.ExecContext(ctx, purgeQuery, shortCode, newURL); err != nil {
+		return fmt.Errorf("failed to purge expired URL: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE urls SET original_url = $2 WHERE short_code = $1`, shortCode, newURL)
+	if err != nil {
+		if constraint, ok := uniqueConstraint(err); ok && constraint == originalURLConstraint {
+			return repository.ErrDuplicate
+		}
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
 
-	_, err := r.db.ExecContext(ctx, query)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to init schema: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit URL update: %w", err)
 	}
 
 	return nil
 }
+
+// DeleteExpired удаляет истекшие ссылки и их переходы
+func (r *PostgresRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	query := `
+		WITH expired AS (
+			DELETE FROM urls
+			WHERE expires_at IS NOT NULL AND expires_at <= $1
+			RETURNING short_code
+		), purged AS (
+			DELETE FROM clicks WHERE short_code IN (SELECT short_code FROM expired)
+		)
+		SELECT COUNT(*) FROM expired
+	`
+
+	var deleted int64
+	if err := r.db.QueryRowContext(ctx, query, now).Scan(&deleted); err != nil {
+		return 0, fmt.Errorf("failed to delete expired URLs: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// SaveClicks сохраняет пачку переходов одним запросом
+func (r *PostgresRepository) SaveClicks(ctx context.Context, clicks []repository.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO clicks (short_code, clicked_at, referrer, user_agent, ip_hash) VALUES `)
+
+	args := make([]interface{}, 0, len(clicks)*5)
+	for i, click := range clicks {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5)
+		args = append(args, click.ShortCode, click.ClickedAt, click.Referrer, click.UserAgent, click.IPHash)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query.String(), args...); err != nil {
+		return fmt.Errorf("failed to save clicks: %w", err)
+	}
+
+	return nil
+}
+
+// ClickStats считает статистику переходов по ссылке
+func (r *PostgresRepository) ClickStats(ctx context.Context, shortCode string, since time.Time) (*repository.ClickStats, error) {
+	stats := &repository.ClickStats{}
+
+	query := `
+		SELECT COUNT(*), COUNT(DISTINCT ip_hash)
+		FROM clicks
+		WHERE short_code = $1 AND clicked_at >= $2
+	`
+	err := r.db.QueryRowContext(ctx, query, shortCode, since).Scan(&stats.Total, &stats.UniqueVisitors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count clicks: %w", err)
+	}
+
+	if stats.Daily, err = r.clickBuckets(ctx, "day", shortCode, since); err != nil {
+		return nil, err
+	}
+	if stats.Hourly, err = r.clickBuckets(ctx, "hour", shortCode, since); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// clickBuckets группирует переходы по интервалам unit (day или hour) в UTC
+func (r *PostgresRepository) clickBuckets(ctx context.Context, unit, shortCode string, since time.Time) ([]repository.ClickBucket, error) {
+	query := `
+		SELECT date_trunc($1, clicked_at AT TIME ZONE 'UTC') AS bucket, COUNT(*)
+		FROM clicks
+		WHERE short_code = $2 AND clicked_at >= $3
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, unit, shortCode, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to group clicks by %s: %w", unit, err)
+	}
+	defer rows.Close()
+
+	var buckets []repository.ClickBucket
+	for rows.Next() {
+		var bucket repository.ClickBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan click bucket: %w", err)
+		}
+		bucket.Start = bucket.Start.UTC()
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read click buckets: %w", err)
+	}
+
+	return buckets, nil
+}
+
+// linkColumns колонки, которые читает scanLink
+const linkColumns = `id, short_code, original_url, created_at, expires_at, owner, redirect_status`
+
+// rowScanner общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLink читает ссылку из строки выборки linkColumns
+func scanLink(row rowScanner) (*repository.Link, error) {
+	var link repository.Link
+	var expiresAt sql.NullTime
+	if err := row.Scan(&link.ID, &link.ShortCode, &link.OriginalURL, &link.CreatedAt, &expiresAt, &link.Owner, &link.RedirectStatus); err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	return &link, nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Ping проверяет соединение с базой
+func (r *PostgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *PostgresRepository) Close() error {
+	return r.db.Close()
+}
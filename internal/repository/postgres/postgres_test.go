@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"shortURL/internal/repository"
+	"shortURL/internal/repository/repotest"
+)
+
+// Интеграционные тесты идут против локального postgres, DSN в формате key=value:
+//
+//	TEST_POSTGRES_DSN="host=localhost user=postgres password=postgres dbname=shorturl sslmode=disable" go test ./internal/repository/postgres/
+//
+// Каждый тест работает в своей схеме, которая удаляется после теста
+func newTestRepository(t *testing.T) *PostgresRepository {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("shorturl_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+	})
+
+	repo, err := NewPostgresRepository(dsn + " search_path=" + schema)
+	if err != nil {
+		t.Fatalf("NewPostgresRepository() failed: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	if err := repo.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	return repo
+}
+
+func TestPostgresRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.URLRepository {
+		return newTestRepository(t)
+	})
+}
+
+func TestPostgresRepository_Keys(t *testing.T) {
+	repotest.RunKeys(t, func(t *testing.T) repository.KeyRepository {
+		return newTestRepository(t)
+	})
+}
+
+func TestPostgresRepository_Sequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) repository.SequenceRepository {
+		return newTestRepository(t)
+	})
+}
+
+func TestPostgresRepository_UniqueViolation(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	// нарушение уникальности original_url раньше превращалось в непрозрачную ошибку
+	_, err := repo.db.Exec(`INSERT INTO urls (short_code, original_url) VALUES ('xyz789ABC_', 'https://example.com/test')`)
+	constraint, ok := uniqueConstraint(err)
+	if !ok || constraint != originalURLConstraint {
+		t.Errorf("uniqueConstraint() = %q, %v, want %q", constraint, ok, originalURLConstraint)
+	}
+
+	_, err = repo.db.Exec(`INSERT INTO urls (short_code, original_url) VALUES ('abc123XYZ_', 'https://example.com/other')`)
+	constraint, ok = uniqueConstraint(err)
+	if !ok || constraint != shortCodeConstraint {
+		t.Errorf("uniqueConstraint() = %q, %v, want %q", constraint, ok, shortCodeConstraint)
+	}
+}
+
+func TestPostgresRepository_MigrateDownUp(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	m, err := repo.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator() failed: %v", err)
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+
+	reverted, err := m.Down(ctx, len(status))
+	if err != nil {
+		t.Fatalf("Down() failed: %v", err)
+	}
+	if len(reverted) != len(status) {
+		t.Errorf("Down() reverted %d migrations, want %d", len(reverted), len(status))
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() failed: %v", err)
+	}
+	if len(applied) != len(status) {
+		t.Errorf("Up() applied %d migrations, want %d", len(applied), len(status))
+	}
+
+	// повторный Up ничего не делает
+	applied, err = m.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("second Up() = %v, %v, want nothing applied", applied, err)
+	}
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"net/http"
+	"time"
 )
 
 var (
@@ -11,15 +13,86 @@ var (
 	ErrDuplicate     = errors.New("URL already shortened")
 )
 
+// Link сохраненная короткая ссылка
+type Link struct {
+	ID          int64
+	ShortCode   string
+	OriginalURL string
+	CreatedAt   time.Time
+
+	// Owner id API ключа, создавшего ссылку, пустой для ссылок без владельца
+	Owner string
+
+	// ExpiresAt момент истечения ссылки, nil если ссылка бессрочная
+	ExpiresAt *time.Time
+
+	// RedirectStatus HTTP код редиректа, 0 значит код по умолчанию из конфига
+	RedirectStatus int
+}
+
+// ValidRedirectStatus можно ли отдавать редирект с этим кодом
+func ValidRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// PermanentRedirect постоянный ли редирект, такой браузеры кэшируют
+func PermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// OriginalKey ключ уникальности URL: владелец и адрес
+func OriginalKey(owner, originalURL string) string {
+	return owner + "\x00" + originalURL
+}
+
+// Expired истекла ли ссылка к моменту now
+func (l *Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(now)
+}
+
+// SequenceRepository счетчик для последовательных кодов
+type SequenceRepository interface {
+	// NextSequence возвращает следующий номер, номера не повторяются
+	NextSequence(ctx context.Context) (uint64, error)
+}
+
 type URLRepository interface {
-	// Save сохраняет новый shortURL
-	Save(ctx context.Context, shortCode string, originalURL string) error
+	// Save сохраняет новый shortURL, истекшие ссылки с тем же кодом или URL перезаписываются.
+	// URL уникален в пределах владельца: у разных владельцев на него разные коды
+	Save(ctx context.Context, link *Link) error
+
+	// SaveMany сохраняет ссылки как Save, но за одну операцию хранилища.
+	// errs[i] ошибка i-й ссылки (ErrAlreadyExists, ErrDuplicate или nil),
+	// ссылки проверяются по порядку, в том числе друг с другом. err означает
+	// сбой самого хранилища
+	SaveMany(ctx context.Context, links []*Link) (errs []error, err error)
+
+	// Get получает ссылку по ShortURL, в том числе истекшую
+	Get(ctx context.Context, shortCode string) (*Link, error)
+
+	// GetByOriginal получает shortURL владельца owner по оригинальному,
+	// истекшие ссылки не учитываются
+	GetByOriginal(ctx context.Context, owner, originalURL string) (string, error)
+
+	// Delete удаляет ссылку по shortURL вместе с ее переходами
+	Delete(ctx context.Context, shortCode string) error
+
+	// Update перенаправляет shortURL на новый URL, у одного URL по-прежнему один shortURL
+	Update(ctx context.Context, shortCode string, newURL string) error
+
+	// List возвращает страницу ссылок от новых к старым, начиная после cursor.
+	// nil cursor означает первую страницу
+	List(ctx context.Context, filter ListFilter, cursor *Cursor, limit int) (*LinkPage, error)
 
-	// Get получает оригинальный URL по ShortURL
-	Get(ctx context.Context, shortCode string) (string, error)
+	// DeleteExpired удаляет ссылки, истекшие к моменту now, и возвращает их количество
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
 
-	// GetByOriginal получает shortURL по оригинальному
-	GetByOriginal(ctx context.Context, originalURL string) (string, error)
+	// Ping проверяет, что хранилище доступно
+	Ping(ctx context.Context) error
 
 	// Close закрывает соединение
 	Close() error
@@ -0,0 +1,578 @@
+// Package repotest общие тесты, которые должна проходить любая реализация URLRepository
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"shortURL/internal/repository"
+)
+
+// Factory создает пустой репозиторий для одного теста
+type Factory func(t *testing.T) repository.URLRepository
+
+// Run прогоняет все общие тесты
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("Save", func(t *testing.T) { TestSave(t, newRepo) })
+	t.Run("SaveMany", func(t *testing.T) { TestSaveMany(t, newRepo) })
+	t.Run("ConcurrentSave", func(t *testing.T) { TestConcurrentSave(t, newRepo) })
+	t.Run("Expiration", func(t *testing.T) { TestExpiration(t, newRepo) })
+	t.Run("DeleteUpdate", func(t *testing.T) { TestDeleteUpdate(t, newRepo) })
+	t.Run("List", func(t *testing.T) { TestList(t, newRepo) })
+	t.Run("Owner", func(t *testing.T) { TestOwner(t, newRepo) })
+	t.Run("Ping", func(t *testing.T) { TestPing(t, newRepo) })
+}
+
+// TestOwner проверяет, что владелец сохраняется и по нему фильтруется List
+func TestOwner(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "aaaaaaaaaa", OriginalURL: "https://example.com/a", Owner: "alice"})
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "bbbbbbbbbb", OriginalURL: "https://example.com/b", Owner: "bob"})
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "cccccccccc", OriginalURL: "https://example.com/c"})
+
+	link, err := repo.Get(ctx, "aaaaaaaaaa")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if link.Owner != "alice" {
+		t.Errorf("Get() Owner = %q, want %q", link.Owner, "alice")
+	}
+
+	page, err := repo.List(ctx, repository.ListFilter{Owner: "bob"}, nil, 10)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(page.Links) != 1 || page.Links[0].ShortCode != "bbbbbbbbbb" {
+		t.Errorf("List() by owner = %v, want only bbbbbbbbbb", page.Links)
+	}
+
+	// URL уникален в пределах владельца: у bob свой код на адрес alice
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "aaaaaaaaaa", OriginalURL: "https://example.com/a", Owner: "bob"}); !errors.Is(err, repository.ErrAlreadyExists) {
+		t.Errorf("Save() code of other owner error = %v, want %v", err, repository.ErrAlreadyExists)
+	}
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "dddddddddd", OriginalURL: "https://example.com/a", Owner: "bob"}); err != nil {
+		t.Fatalf("Save() same URL for other owner failed: %v", err)
+	}
+	for owner, want := range map[string]string{"alice": "aaaaaaaaaa", "bob": "dddddddddd"} {
+		if code, err := repo.GetByOriginal(ctx, owner, "https://example.com/a"); err != nil || code != want {
+			t.Errorf("GetByOriginal(%s) = %s, %v, want %s", owner, code, err, want)
+		}
+	}
+	if _, err := repo.GetByOriginal(ctx, "", "https://example.com/a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByOriginal() without owner error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	errs, err := repo.SaveMany(ctx, []*repository.Link{
+		{ShortCode: "bbbbbbbbbb", OriginalURL: "https://example.com/b", Owner: "alice"},
+		{ShortCode: "eeeeeeeeee", OriginalURL: "https://example.com/b", Owner: "alice"},
+	})
+	if err != nil {
+		t.Fatalf("SaveMany() failed: %v", err)
+	}
+	if !errors.Is(errs[0], repository.ErrAlreadyExists) || errs[1] != nil {
+		t.Errorf("SaveMany() errs = %v, want [%v <nil>]", errs, repository.ErrAlreadyExists)
+	}
+
+	if err := repo.Update(ctx, "cccccccccc", "https://example.com/b"); err != nil {
+		t.Errorf("Update() to URL of other owner failed: %v", err)
+	}
+}
+
+// TestPing проверяет, что открытое хранилище отвечает на Ping
+func TestPing(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+
+	if err := repo.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() failed: %v", err)
+	}
+}
+
+// TestSave проверяет сохранение, поиск и разбор конфликтов
+func TestSave(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	link := &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"}
+	if err := repo.Save(ctx, link); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if link.ID == 0 || link.CreatedAt.IsZero() {
+		t.Errorf("Save() did not fill ID and CreatedAt: %+v", link)
+	}
+
+	got, err := repo.Get(ctx, "abc123XYZ_")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if got.OriginalURL != link.OriginalURL || got.ID != link.ID || got.ExpiresAt != nil {
+		t.Errorf("Get() = %+v, want %+v", got, link)
+	}
+
+	code, err := repo.GetByOriginal(ctx, "", "https://example.com/test")
+	if err != nil {
+		t.Fatalf("GetByOriginal() failed: %v", err)
+	}
+	if code != "abc123XYZ_" {
+		t.Errorf("GetByOriginal() = %s, want abc123XYZ_", code)
+	}
+
+	permanent := &repository.Link{ShortCode: "perm123XYZ", OriginalURL: "https://example.com/permanent", RedirectStatus: http.StatusPermanentRedirect}
+	if err := repo.Save(ctx, permanent); err != nil {
+		t.Fatalf("Save() with redirect status failed: %v", err)
+	}
+	if got, err := repo.Get(ctx, "perm123XYZ"); err != nil || got.RedirectStatus != http.StatusPermanentRedirect {
+		t.Errorf("Get() = %+v, %v, want RedirectStatus %d", got, err, http.StatusPermanentRedirect)
+	}
+	if got.RedirectStatus != 0 {
+		t.Errorf("Get() RedirectStatus = %d for link without status, want 0", got.RedirectStatus)
+	}
+
+	if _, err := repo.Get(ctx, "notexists_"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get() missing error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if _, err := repo.GetByOriginal(ctx, "", "https://notexists.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByOriginal() missing error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	// та же пара код-URL сохраняется повторно без ошибки
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/test"}); err != nil {
+		t.Errorf("Save() same mapping failed: %v", err)
+	}
+
+	err = repo.Save(ctx, &repository.Link{ShortCode: "xyz789ABC_", OriginalURL: "https://example.com/test"})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("Save() same URL error = %v, want %v", err, repository.ErrDuplicate)
+	}
+
+	err = repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/other"})
+	if !errors.Is(err, repository.ErrAlreadyExists) {
+		t.Errorf("Save() same code error = %v, want %v", err, repository.ErrAlreadyExists)
+	}
+}
+
+// TestSaveMany проверяет пакетное сохранение и ошибки отдельных ссылок
+func TestSaveMany(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	if errs, err := repo.SaveMany(ctx, nil); err != nil || len(errs) != 0 {
+		t.Errorf("SaveMany(nil) = %v, %v", errs, err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	for _, link := range []*repository.Link{
+		{ShortCode: "existing__", OriginalURL: "https://example.com/existing"},
+		{ShortCode: "expired___", OriginalURL: "https://example.com/expired", ExpiresAt: &past},
+	} {
+		if err := repo.Save(ctx, link); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	links := []*repository.Link{
+		{ShortCode: "batch1____", OriginalURL: "https://example.com/1", RedirectStatus: http.StatusMovedPermanently},
+		{ShortCode: "batch2____", OriginalURL: "https://example.com/2"},
+		{ShortCode: "existing__", OriginalURL: "https://example.com/other"},
+		{ShortCode: "batch3____", OriginalURL: "https://example.com/existing"},
+		{ShortCode: "existing__", OriginalURL: "https://example.com/existing"},
+		{ShortCode: "batch1____", OriginalURL: "https://example.com/3"},
+		{ShortCode: "batch4____", OriginalURL: "https://example.com/2"},
+		{ShortCode: "expired___", OriginalURL: "https://example.com/reused"},
+	}
+	want := []error{nil, nil, repository.ErrAlreadyExists, repository.ErrDuplicate, nil, repository.ErrAlreadyExists, repository.ErrDuplicate, nil}
+
+	errs, err := repo.SaveMany(ctx, links)
+	if err != nil {
+		t.Fatalf("SaveMany() failed: %v", err)
+	}
+	if len(errs) != len(links) {
+		t.Fatalf("SaveMany() returned %d errors, want %d", len(errs), len(links))
+	}
+	for i := range links {
+		if !errors.Is(errs[i], want[i]) || (want[i] == nil && errs[i] != nil) {
+			t.Errorf("SaveMany() errs[%d] = %v, want %v", i, errs[i], want[i])
+		}
+	}
+
+	for _, i := range []int{0, 1, 7} {
+		if links[i].ID == 0 || links[i].CreatedAt.IsZero() {
+			t.Errorf("SaveMany() did not fill ID and CreatedAt: %+v", links[i])
+		}
+		got, err := repo.Get(ctx, links[i].ShortCode)
+		if err != nil || got.OriginalURL != links[i].OriginalURL || got.ID != links[i].ID {
+			t.Errorf("Get(%s) = %+v, %v, want %+v", links[i].ShortCode, got, err, links[i])
+		}
+	}
+	if got, _ := repo.Get(ctx, "batch1____"); got == nil || got.RedirectStatus != http.StatusMovedPermanently {
+		t.Errorf("Get() = %+v, want RedirectStatus %d", got, http.StatusMovedPermanently)
+	}
+	if _, err := repo.Get(ctx, "batch3____"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get() rejected link error = %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+// TestConcurrentSave проверяет, что при гонке побеждает ровно один Save
+func TestConcurrentSave(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	const workers = 20
+
+	run := func(link func(i int) *repository.Link, conflict error) {
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- repo.Save(ctx, link(i))
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		saved := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				saved++
+			case !errors.Is(err, conflict):
+				t.Errorf("concurrent Save() error = %v, want %v", err, conflict)
+			}
+		}
+		if saved != 1 {
+			t.Errorf("concurrent Save() succeeded %d times, want 1", saved)
+		}
+	}
+
+	run(func(i int) *repository.Link {
+		return &repository.Link{ShortCode: fmt.Sprintf("race%06d", i), OriginalURL: "https://example.com/race"}
+	}, repository.ErrDuplicate)
+
+	run(func(i int) *repository.Link {
+		return &repository.Link{ShortCode: "samecode__", OriginalURL: fmt.Sprintf("https://example.com/race/%d", i)}
+	}, repository.ErrAlreadyExists)
+}
+
+// TestExpiration проверяет истекшие ссылки и их очистку
+func TestExpiration(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	save := func(code, url string, expiresAt *time.Time) {
+		t.Helper()
+		if err := repo.Save(ctx, &repository.Link{ShortCode: code, OriginalURL: url, ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("Save(%s) failed: %v", code, err)
+		}
+	}
+
+	save("expired___", "https://example.com/old", &past)
+	save("active____", "https://example.com/new", &future)
+
+	link, err := repo.Get(ctx, "expired___")
+	if err != nil {
+		t.Fatalf("Get() expired failed: %v", err)
+	}
+	if !link.Expired(time.Now()) {
+		t.Errorf("Get() expired link has ExpiresAt %v", link.ExpiresAt)
+	}
+	if _, err := repo.GetByOriginal(ctx, "", "https://example.com/old"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByOriginal() expired error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	// истекший код и истекший URL можно занять заново
+	save("expired___", "https://example.com/reused", nil)
+	save("expired2__", "https://example.com/old2", &past)
+	save("renewed___", "https://example.com/old2", nil)
+
+	save("expired3__", "https://example.com/old3", &past)
+	deleted, err := repo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("DeleteExpired() failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("DeleteExpired() = %d, want 1", deleted)
+	}
+
+	if _, err := repo.Get(ctx, "expired3__"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get() after DeleteExpired() error = %v, want %v", err, repository.ErrNotFound)
+	}
+	for _, code := range []string{"active____", "expired___", "renewed___"} {
+		if _, err := repo.Get(ctx, code); err != nil {
+			t.Errorf("Get(%s) failed: %v", code, err)
+		}
+	}
+}
+
+// TestDeleteUpdate проверяет удаление и перенаправление ссылок
+func TestDeleteUpdate(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	for code, url := range map[string]string{
+		"abc123XYZ_": "https://example.com/old",
+		"xyz789ABC_": "https://example.com/taken",
+	} {
+		if err := repo.Save(ctx, &repository.Link{ShortCode: code, OriginalURL: url}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	if err := repo.Update(ctx, "abc123XYZ_", "https://example.com/new"); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if link, _ := repo.Get(ctx, "abc123XYZ_"); link == nil || link.OriginalURL != "https://example.com/new" {
+		t.Errorf("Get() after Update() = %+v", link)
+	}
+	if _, err := repo.GetByOriginal(ctx, "", "https://example.com/old"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByOriginal(old) error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if err := repo.Update(ctx, "abc123XYZ_", "https://example.com/taken"); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("Update() to taken URL error = %v, want %v", err, repository.ErrDuplicate)
+	}
+	if err := repo.Update(ctx, "notexists_", "https://example.com/x"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Update() missing error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	if err := repo.Delete(ctx, "abc123XYZ_"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := repo.Get(ctx, "abc123XYZ_"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if err := repo.Delete(ctx, "abc123XYZ_"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "abc123XYZ_", OriginalURL: "https://example.com/new"}); err != nil {
+		t.Errorf("Save() after Delete() failed: %v", err)
+	}
+}
+
+// TestList проверяет пагинацию и фильтрацию List
+func TestList(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	urls := []string{
+		"https://example.com/a",
+		"https://Example.com/b",
+		"https://other.org/example",
+		"https://user@example.com:8443/c?q=1",
+		"https://blog.example.com/d_100%",
+	}
+
+	var codes []string
+	for i, url := range urls {
+		code := fmt.Sprintf("list%06d", i)
+		if err := repo.Save(ctx, &repository.Link{ShortCode: code, OriginalURL: url}); err != nil {
+			t.Fatalf("Save(%s) failed: %v", url, err)
+		}
+		codes = append(codes, code)
+	}
+
+	t.Run("pagination", func(t *testing.T) {
+		var got []string
+		var cursor *repository.Cursor
+		pages := 0
+		for {
+			page, err := repo.List(ctx, repository.ListFilter{}, cursor, 2)
+			if err != nil {
+				t.Fatalf("List() failed: %v", err)
+			}
+			pages++
+			if len(page.Links) > 2 {
+				t.Fatalf("List() returned %d links, limit 2", len(page.Links))
+			}
+			for _, link := range page.Links {
+				got = append(got, link.ShortCode)
+			}
+			if page.Next == nil {
+				break
+			}
+
+			// курсор переживает кодирование в API
+			cursor, err = repository.DecodeCursor(page.Next.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor() failed: %v", err)
+			}
+		}
+
+		if pages != 3 {
+			t.Errorf("List() returned %d pages, want 3", pages)
+		}
+		// от новых к старым
+		want := []string{codes[4], codes[3], codes[2], codes[1], codes[0]}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("List() order = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("exact page", func(t *testing.T) {
+		page, err := repo.List(ctx, repository.ListFilter{}, nil, len(urls))
+		if err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+		if len(page.Links) != len(urls) || page.Next != nil {
+			t.Errorf("List() = %d links, next %v, want %d links and no next", len(page.Links), page.Next, len(urls))
+		}
+	})
+
+	filters := []struct {
+		name   string
+		filter repository.ListFilter
+		want   []string
+	}{
+		{
+			name:   "query is case insensitive",
+			filter: repository.ListFilter{Query: "EXAMPLE"},
+			want:   []string{codes[4], codes[3], codes[2], codes[1], codes[0]},
+		},
+		{
+			name:   "query with like wildcards",
+			filter: repository.ListFilter{Query: "d_100%"},
+			want:   []string{codes[4]},
+		},
+		{
+			name:   "query without matches",
+			filter: repository.ListFilter{Query: "_1%"},
+			want:   []string{},
+		},
+		{
+			name:   "host",
+			filter: repository.ListFilter{Host: "example.com"},
+			want:   []string{codes[3], codes[1], codes[0]},
+		},
+		{
+			name:   "host and query",
+			filter: repository.ListFilter{Host: "EXAMPLE.COM", Query: "/b"},
+			want:   []string{codes[1]},
+		},
+	}
+
+	for _, tt := range filters {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.List(ctx, tt.filter, nil, 10)
+			if err != nil {
+				t.Fatalf("List() failed: %v", err)
+			}
+
+			got := []string{}
+			for _, link := range page.Links {
+				got = append(got, link.ShortCode)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("List(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+// KeyFactory создает пустое хранилище ключей для одного теста
+type KeyFactory func(t *testing.T) repository.KeyRepository
+
+// RunKeys прогоняет общие тесты хранилища API ключей
+func RunKeys(t *testing.T, newRepo KeyFactory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	first := &repository.APIKey{ID: "key1", Name: "first", SecretHash: "hash1"}
+	if err := repo.SaveKey(ctx, first); err != nil {
+		t.Fatalf("SaveKey() failed: %v", err)
+	}
+	if first.CreatedAt.IsZero() {
+		t.Errorf("SaveKey() did not set CreatedAt")
+	}
+	if err := repo.SaveKey(ctx, &repository.APIKey{ID: "key1", SecretHash: "other"}); err != repository.ErrAlreadyExists {
+		t.Errorf("SaveKey() with taken id error = %v, want %v", err, repository.ErrAlreadyExists)
+	}
+
+	second := &repository.APIKey{ID: "key2", Name: "second", SecretHash: "hash2", Admin: true, CreatedAt: first.CreatedAt.Add(time.Second)}
+	_ = repo.SaveKey(ctx, second)
+
+	key, err := repo.GetKey(ctx, "key2")
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+	if key.Name != "second" || key.SecretHash != "hash2" || !key.Admin || key.Revoked() {
+		t.Errorf("GetKey() = %+v", key)
+	}
+	if _, err := repo.GetKey(ctx, "missing"); err != repository.ErrNotFound {
+		t.Errorf("GetKey() missing error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	revokedAt := time.Now().UTC().Truncate(time.Second)
+	if err := repo.RevokeKey(ctx, "key1", revokedAt); err != nil {
+		t.Fatalf("RevokeKey() failed: %v", err)
+	}
+	// повторный отзыв не сдвигает время
+	_ = repo.RevokeKey(ctx, "key1", revokedAt.Add(time.Hour))
+	if err := repo.RevokeKey(ctx, "missing", revokedAt); err != repository.ErrNotFound {
+		t.Errorf("RevokeKey() missing error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	key, _ = repo.GetKey(ctx, "key1")
+	if !key.Revoked() || !key.RevokedAt.Equal(revokedAt) {
+		t.Errorf("GetKey() after RevokeKey() RevokedAt = %v, want %v", key.RevokedAt, revokedAt)
+	}
+
+	keys, err := repo.ListKeys(ctx)
+	if err != nil {
+		t.Fatalf("ListKeys() failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "key1" || keys[1].ID != "key2" {
+		t.Errorf("ListKeys() = %v, want key1, key2", keys)
+	}
+}
+
+// SequenceFactory создает пустой счетчик для одного теста
+type SequenceFactory func(t *testing.T) repository.SequenceRepository
+
+// RunSequence проверяет, что номера растут и не повторяются при параллельных вызовах
+func RunSequence(t *testing.T, newRepo SequenceFactory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	first, err := repo.NextSequence(ctx)
+	if err != nil {
+		t.Fatalf("NextSequence() failed: %v", err)
+	}
+	second, _ := repo.NextSequence(ctx)
+	if second <= first {
+		t.Errorf("NextSequence() = %d after %d, want growing numbers", second, first)
+	}
+
+	const workers = 8
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[uint64]bool)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				n, err := repo.NextSequence(ctx)
+				if err != nil {
+					t.Errorf("NextSequence() failed: %v", err)
+					return
+				}
+				mu.Lock()
+				if seen[n] || n <= second {
+					t.Errorf("NextSequence() returned %d twice or out of order", n)
+				}
+				seen[n] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sweeper в фоне периодически удаляет истекшие ссылки из репозитория
+type Sweeper struct {
+	repo     URLRepository
+	interval time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewSweeper(repo URLRepository, interval time.Duration) *Sweeper {
+	return &Sweeper{
+		repo:     repo,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start запускает очистку в отдельной горутине
+func (s *Sweeper) Start() {
+	go s.run()
+}
+
+// Stop останавливает очистку и ждет завершения текущего прохода
+func (s *Sweeper) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *Sweeper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+func (s *Sweeper) sweep() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+	defer cancel()
+
+	// прерываем проход, если во время него пришел Stop
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	deleted, err := s.repo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		slog.Error("failed to delete expired links", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.Info("deleted expired links", "count", deleted)
+	}
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingRepository struct {
+	URLRepository
+	calls atomic.Int32
+}
+
+func (r *countingRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.calls.Add(1)
+	return 0, nil
+}
+
+func TestSweeper(t *testing.T) {
+	repo := &countingRepository{}
+	sweeper := NewSweeper(repo, 5*time.Millisecond)
+	sweeper.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for repo.calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if repo.calls.Load() < 2 {
+		t.Fatalf("DeleteExpired() called %d times, want at least 2", repo.calls.Load())
+	}
+
+	sweeper.Stop()
+	calls := repo.calls.Load()
+
+	time.Sleep(20 * time.Millisecond)
+	if repo.calls.Load() != calls {
+		t.Errorf("DeleteExpired() called after Stop()")
+	}
+
+	// повторный Stop не блокируется
+	sweeper.Stop()
+}
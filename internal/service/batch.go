@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"shortURL/internal/repository"
+)
+
+// MaxBatchSize сколько ссылок можно создать одним вызовом CreateBatch
+const MaxBatchSize = 1000
+
+// batchWorkers сколько адресов пакета проверяется параллельно: политика
+// и защита от петель могут ходить в сеть
+const batchWorkers = 8
+
+// ErrBatchTooLarge в пакете больше MaxBatchSize ссылок
+var ErrBatchTooLarge = fmt.Errorf("batch exceeds %d links", MaxBatchSize)
+
+// BatchItem одна ссылка пакета
+type BatchItem struct {
+	URL     string
+	Options CreateOptions
+}
+
+// BatchResult код ссылки или ошибка, та же, что вернул бы CreateWithOptions
+type BatchResult struct {
+	ShortCode string
+	Err       error
+}
+
+// batchEntry ссылка пакета, которую еще предстоит сохранить
+type batchEntry struct {
+	index int
+	link  *repository.Link
+	alias bool
+}
+
+// CreateBatch создает ссылки пакетом. Каждая проверяется как в
+// CreateWithOptions, а сохраняются они через SaveMany: один вызов на
+// попытку генерации, повторно идут только коды, попавшие в коллизию.
+// Ошибка возвращается, только если пакет не удалось обработать целиком
+func (s *URLService) CreateBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	if len(items) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, len(items))
+	links := make([]*repository.Link, len(items))
+
+	var g errgroup.Group
+	g.SetLimit(batchWorkers)
+	for i, item := range items {
+		g.Go(func() error {
+			links[i], results[i].Err = s.newLink(ctx, item.URL, item.Options)
+			return nil
+		})
+	}
+	g.Wait()
+
+	var pending []batchEntry
+	for i, link := range links {
+		if results[i].Err == nil {
+			pending = append(pending, batchEntry{index: i, link: link, alias: items[i].Options.Alias != ""})
+		}
+	}
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == maxGenerateAttempts {
+			for _, e := range pending {
+				results[e.index].Err = ErrCollision
+			}
+			break
+		}
+
+		batch := make([]*repository.Link, len(pending))
+		for j, e := range pending {
+			if !e.alias {
+				code, err := s.generator.Generate(ctx, e.link.OriginalURL, attempt)
+				if err != nil {
+					return nil, logError(ctx, fmt.Errorf("failed to generate short code: %w", err))
+				}
+				e.link.ShortCode = code
+			}
+			batch[j] = e.link
+		}
+
+		errs, err := s.repo.SaveMany(ctx, batch)
+		if err != nil {
+			return nil, logError(ctx, fmt.Errorf("failed to save URLs: %w", err))
+		}
+
+		var retry []batchEntry
+		for j, e := range pending {
+			result := &results[e.index]
+			switch {
+			case errs[j] == nil && e.alias:
+				result.ShortCode, result.Err = s.savedAlias(ctx, e.link)
+			case errs[j] == nil:
+				result.ShortCode = e.link.ShortCode
+			case e.alias:
+				result.Err = aliasError(ctx, errs[j])
+			case errors.Is(errs[j], repository.ErrAlreadyExists):
+				// код занят другим URL, на следующей попытке генерируем другой
+				retry = append(retry, e)
+			case errors.Is(errs[j], repository.ErrDuplicate):
+				// URL уже сокращен раньше или этим же пакетом
+				result.ShortCode, result.Err = s.existing(ctx, e.link)
+			default:
+				result.Err = logError(ctx, fmt.Errorf("failed to save URL: %w", errs[j]))
+			}
+		}
+		pending = retry
+	}
+
+	return results, nil
+}
@@ -4,68 +4,645 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
+	"shortURL/internal/analytics"
+	"shortURL/internal/auth"
+	"shortURL/internal/loopguard"
+	"shortURL/internal/policy"
 	"shortURL/internal/repository"
 	"shortURL/pkg/shortener"
+	"shortURL/pkg/urlnorm"
 )
 
 var (
-	ErrInvalidURL = errors.New("invalid URL format")
+	ErrInvalidURL   = errors.New("invalid URL format")
+	ErrCollision    = errors.New("failed to generate unique short code")
+	ErrInvalidAlias = errors.New("invalid alias")
+	ErrAliasTaken   = errors.New("alias is already taken")
+	ErrAliasBlocked = errors.New("alias contains a blocked word")
+	ErrURLShortened = errors.New("URL already has a short code")
+	ErrInvalidTTL   = errors.New("invalid expiration")
+	ErrExpired      = errors.New("short URL has expired")
+	ErrNoAnalytics  = errors.New("click tracking is disabled")
+	ErrRedirectCode = errors.New("invalid redirect status")
+
+	// ErrOptionsConflict URL уже сокращен, но с другими параметрами ссылки,
+	// это тоже ErrURLShortened
+	ErrOptionsConflict = fmt.Errorf("%w with different options", ErrURLShortened)
+
+	// ErrMistyped контрольный символ кода не сходится, это тоже ErrNotFound
+	ErrMistyped = fmt.Errorf("%w: short code has a typo", repository.ErrNotFound)
+
+	// ErrForbiddenURL адрес запрещен политикой, причина в *policy.Violation
+	ErrForbiddenURL = policy.ErrForbiddenURL
+
+	// ErrSelfReference адрес ведет на наш же сокращатель
+	ErrSelfReference = loopguard.ErrSelfReference
+	// ErrRedirectLoop цепочка редиректов возвращается к самой себе
+	ErrRedirectLoop = loopguard.ErrRedirectLoop
+)
+
+// reservedAliases пути, занятые роутами, их нельзя брать в качестве алиаса
+var reservedAliases = map[string]bool{
+	"shorten": true,
+	"api":     true,
+	"metrics": true,
+	"healthz": true,
+	"readyz":  true,
+}
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
 )
 
+// maxGenerateAttempts сколько раз пробуем сгенерировать код при коллизиях
+const maxGenerateAttempts = 8
+
+// MaxTTL самый долгий срок жизни ссылки, который можно задать
+const MaxTTL = 100 * 365 * 24 * time.Hour
+
+// maxSuggestions сколько вариантов предлагаем для кода с опечаткой
+const maxSuggestions = 3
+
+// maxSuggestLookups сколько кандидатов на подсказку ищем в хранилище, запрос
+// с опечаткой не должен стоить больше нескольких обращений
+const maxSuggestLookups = 6
+
 type URLService struct {
 	repo repository.URLRepository
+
+	// generator выдает коды для новых ссылок, по умолчанию хеш от URL
+	generator shortener.Generator
+
+	// now текущее время, в тестах подменяется
+	now func() time.Time
+
+	// redirectStatus код редиректа для ссылок, у которых он не задан
+	redirectStatus int
+
+	// tracker пишет переходы, nil если аналитика выключена
+	tracker *analytics.Tracker
+
+	// normalize приводит URL к каноническому виду, nil если выключено
+	normalize func(rawURL string) (string, error)
+
+	// policy проверяет, можно ли сокращать адрес, nil если проверок нет
+	policy *policy.Policy
+
+	// guard ловит ссылки на наш домен и петли редиректов, nil если выключено
+	guard *loopguard.Guard
+
+	// words запрещенные слова в кодах и алиасах, nil если фильтр выключен
+	words *shortener.WordFilter
+}
+
+// Option настраивает URLService
+type Option func(*URLService)
+
+// WithTracker включает учет переходов по ссылкам
+func WithTracker(tracker *analytics.Tracker) Option {
+	return func(s *URLService) {
+		s.tracker = tracker
+	}
+}
+
+// WithNormalization включает нормализацию URL перед сохранением, чтобы
+// один адрес в разной записи получал один код
+func WithNormalization(opts urlnorm.Options) Option {
+	return func(s *URLService) {
+		s.normalize = func(rawURL string) (string, error) {
+			return urlnorm.Normalize(rawURL, opts)
+		}
+	}
 }
 
-func NewURLService(repo repository.URLRepository) *URLService {
-	return &URLService{
-		repo: repo,
+// WithPolicy запрещает сокращать адреса, не прошедшие политику
+func WithPolicy(p *policy.Policy) Option {
+	return func(s *URLService) {
+		s.policy = p
 	}
 }
 
+// WithGenerator задает способ генерации кодов
+func WithGenerator(g shortener.Generator) Option {
+	return func(s *URLService) {
+		s.generator = g
+	}
+}
+
+// WithRedirectStatus задает код редиректа по умолчанию, без опции 302
+func WithRedirectStatus(status int) Option {
+	return func(s *URLService) {
+		s.redirectStatus = status
+	}
+}
+
+// WithLoopGuard не дает сокращать ссылки на самих себя и петли через
+// другие сокращатели
+func WithLoopGuard(g *loopguard.Guard) Option {
+	return func(s *URLService) {
+		s.guard = g
+	}
+}
+
+// WithWordFilter не выдает коды со словами из фильтра и не принимает
+// такие алиасы. Генератор оборачивается независимо от порядка опций
+func WithWordFilter(f *shortener.WordFilter) Option {
+	return func(s *URLService) {
+		s.words = f
+	}
+}
+
+func NewURLService(repo repository.URLRepository, opts ...Option) *URLService {
+	s := &URLService{
+		repo:      repo,
+		generator: shortener.HashGenerator{},
+		now:       time.Now,
+
+		redirectStatus: http.StatusFound,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.words != nil {
+		s.generator = shortener.NewFilteredGenerator(s.generator, s.words)
+	}
+
+	return s
+}
+
+// CreateOptions дополнительные параметры создания shortURL
+type CreateOptions struct {
+	// Alias пользовательский код, если пустой то код генерируется
+	Alias string
+
+	// ExpiresAt абсолютный момент истечения ссылки
+	ExpiresAt *time.Time
+
+	// TTL время жизни ссылки, нельзя задавать вместе с ExpiresAt
+	TTL time.Duration
+
+	// RedirectStatus код редиректа 301, 302, 307 или 308, 0 берет код по умолчанию
+	RedirectStatus int
+}
+
 // Create создаем shortURL
 func (s *URLService) Create(ctx context.Context, originalURL string) (string, error) {
-	if err := s.validateURL(originalURL); err != nil {
+	return s.CreateWithOptions(ctx, originalURL, CreateOptions{})
+}
+
+// CreateWithOptions создаем shortURL с учетом опций
+func (s *URLService) CreateWithOptions(ctx context.Context, originalURL string, opts CreateOptions) (string, error) {
+	link, err := s.newLink(ctx, originalURL, opts)
+	if err != nil {
 		return "", err
 	}
 
-	// Проверяем есть ли у юрл шортюрл
-	existingShort, err := s.repo.GetByOriginal(ctx, originalURL)
-	if err == nil {
-		// URL already shortened, return existing code
-		return existingShort, nil
+	if opts.Alias != "" {
+		return s.createAlias(ctx, link)
 	}
-	if err != nil && !errors.Is(err, repository.ErrNotFound) {
-		return "", fmt.Errorf("failed to check existing URL: %w", err)
+
+	// Проверяем есть ли у юрл шортюрл этого же владельца, чужие коды не
+	// отдаем: иначе один ключ мог бы менять и удалять ссылку другого
+	existingShort, err := s.existing(ctx, link)
+	if err == nil || !errors.Is(err, repository.ErrNotFound) {
+		return existingShort, err
 	}
 
-	// генерируем shortUrl
-	shortCode := shortener.Generate(originalURL)
+	// генерируем shortUrl, при коллизии просим у генератора следующий код
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		link.ShortCode, err = s.generator.Generate(ctx, link.OriginalURL, attempt)
+		if err != nil {
+			return "", logError(ctx, fmt.Errorf("failed to generate short code: %w", err))
+		}
 
-	// сохраняем
-	err = s.repo.Save(ctx, shortCode, originalURL)
-	if err != nil {
-		if errors.Is(err, repository.ErrAlreadyExists) {
-			return "", fmt.Errorf("short code collision detected: %w", err)
+		err = s.repo.Save(ctx, link)
+		switch {
+		case err == nil:
+			return link.ShortCode, nil
+		case errors.Is(err, repository.ErrAlreadyExists):
+			// код занят другим URL
+			continue
+		case errors.Is(err, repository.ErrDuplicate):
+			// URL успели сократить параллельно, отдаем существующий код
+			return s.existing(ctx, link)
+		default:
+			return "", logError(ctx, fmt.Errorf("failed to save URL: %w", err))
 		}
-		return "", fmt.Errorf("failed to save URL: %w", err)
+	}
+
+	return "", ErrCollision
+}
+
+// existing отдает код, под которым владелец уже сократил URL ссылки link.
+// Если параметры сохраненной ссылки другие, отдавать ее код значило бы
+// молча проигнорировать их, поэтому возвращается ErrOptionsConflict.
+// repository.ErrNotFound, если URL еще не сокращен
+func (s *URLService) existing(ctx context.Context, link *repository.Link) (string, error) {
+	shortCode, err := s.repo.GetByOriginal(ctx, link.Owner, link.OriginalURL)
+	if err != nil {
+		return "", logError(ctx, fmt.Errorf("failed to get existing short code: %w", err))
+	}
+
+	found, err := s.repo.Get(ctx, shortCode)
+	if err != nil {
+		return "", logError(ctx, fmt.Errorf("failed to get existing link: %w", err))
+	}
+	if !s.sameOptions(found, link) {
+		return "", ErrOptionsConflict
 	}
 
 	return shortCode, nil
 }
 
+// sameOptions совпадают ли у сохраненной ссылки и новой код редиректа и срок жизни
+func (s *URLService) sameOptions(found, link *repository.Link) bool {
+	return s.effectiveStatus(found.RedirectStatus) == s.effectiveStatus(link.RedirectStatus) && sameExpiry(found.ExpiresAt, link.ExpiresAt)
+}
+
+// sameExpiry истекают ли ссылки в один момент. Хранилище может округлить
+// время, поэтому разница меньше миллисекунды не считается. TTL отсчитывается
+// от запроса, так что его повтор с тем же TTL тоже конфликтует
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Sub(*b).Abs() < time.Millisecond
+}
+
+// effectiveStatus код редиректа с учетом кода по умолчанию
+func (s *URLService) effectiveStatus(status int) int {
+	if status == 0 {
+		return s.redirectStatus
+	}
+	return status
+}
+
+// newLink проверяет адрес и опции и собирает ссылку. Код заполнен только
+// для алиаса, сгенерированный подставляется при сохранении
+func (s *URLService) newLink(ctx context.Context, originalURL string, opts CreateOptions) (*repository.Link, error) {
+	originalURL, err := s.prepareURL(ctx, originalURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := s.expiresAt(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.RedirectStatus != 0 && !repository.ValidRedirectStatus(opts.RedirectStatus) {
+		return nil, ErrRedirectCode
+	}
+
+	if opts.Alias != "" {
+		if err := s.checkAlias(opts.Alias); err != nil {
+			return nil, err
+		}
+	}
+
+	owner, _ := s.caller(ctx)
+
+	return &repository.Link{
+		ShortCode:   opts.Alias,
+		OriginalURL: originalURL,
+		Owner:       owner,
+		ExpiresAt:   expiresAt,
+
+		RedirectStatus: opts.RedirectStatus,
+	}, nil
+}
+
+// checkAlias можно ли занять алиас
+func (s *URLService) checkAlias(alias string) error {
+	if !shortener.ValidateAlias(alias) || reservedAliases[strings.ToLower(alias)] {
+		return ErrInvalidAlias
+	}
+	if s.words != nil && s.words.Contains(alias) {
+		return ErrAliasBlocked
+	}
+	return nil
+}
+
+// createAlias резервирует проверенный алиас за URL
+func (s *URLService) createAlias(ctx context.Context, link *repository.Link) (string, error) {
+	err := s.repo.Save(ctx, link)
+	if err != nil {
+		return "", aliasError(ctx, err)
+	}
+	return s.savedAlias(ctx, link)
+}
+
+// savedAlias отдает алиас после успешного Save. Повтор того же алиаса, URL
+// и владельца Save принимает молча, поэтому, как и в existing, параметры
+// сверяются с сохраненной ссылкой
+func (s *URLService) savedAlias(ctx context.Context, link *repository.Link) (string, error) {
+	found, err := s.repo.Get(ctx, link.ShortCode)
+	if err != nil {
+		return "", logError(ctx, fmt.Errorf("failed to get saved alias: %w", err))
+	}
+	if !s.sameOptions(found, link) {
+		return "", ErrOptionsConflict
+	}
+	return link.ShortCode, nil
+}
+
+// aliasError переводит ошибку сохранения алиаса в ошибку сервиса
+func aliasError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrAlreadyExists):
+		return ErrAliasTaken
+	case errors.Is(err, repository.ErrDuplicate):
+		return ErrURLShortened
+	default:
+		return logError(ctx, fmt.Errorf("failed to save alias: %w", err))
+	}
+}
+
 func (s *URLService) Resolve(ctx context.Context, shortCode string) (string, error) {
+	link, err := s.ResolveLink(ctx, shortCode)
+	if err != nil {
+		return "", err
+	}
+
+	return link.OriginalURL, nil
+}
+
+// ResolveLink как Resolve, но отдает всю ссылку. RedirectStatus всегда
+// заполнен: для ссылок без своего кода подставляется код по умолчанию
+func (s *URLService) ResolveLink(ctx context.Context, shortCode string) (*repository.Link, error) {
+	if !shortener.Validate(shortCode) {
+		if shortener.Mistyped(shortCode) {
+			return nil, ErrMistyped
+		}
+		return nil, repository.ErrNotFound
+	}
+
+	link, err := s.repo.Get(ctx, shortCode)
+	if err != nil {
+		// похожий на код с опечаткой может оказаться алиасом, поэтому
+		// опечаткой он считается, только если его нет в хранилище
+		if errors.Is(err, repository.ErrNotFound) && shortener.Mistyped(shortCode) {
+			return nil, ErrMistyped
+		}
+		return nil, logError(ctx, err)
+	}
+
+	if link.Expired(s.now()) {
+		return nil, ErrExpired
+	}
+
+	if link.RedirectStatus == 0 {
+		link.RedirectStatus = s.redirectStatus
+	}
+
+	return link, nil
+}
+
+// Suggest существующие ссылки, код которых отличается от shortCode
+// перестановкой двух соседних символов. Для кода с опечаткой (ErrMistyped).
+// В хранилище проверяется не больше maxSuggestLookups кандидатов
+func (s *URLService) Suggest(ctx context.Context, shortCode string) []string {
+	var found []string
+
+	candidates := shortener.Suggest(shortCode)
+	for _, candidate := range candidates[:min(len(candidates), maxSuggestLookups)] {
+		if len(found) == maxSuggestions {
+			break
+		}
+
+		link, err := s.repo.Get(ctx, candidate)
+		if err != nil || link.Expired(s.now()) {
+			continue
+		}
+		found = append(found, candidate)
+	}
+
+	return found
+}
+
+// Delete удаляет ссылку
+func (s *URLService) Delete(ctx context.Context, shortCode string) error {
+	if _, err := s.getOwned(ctx, shortCode); err != nil {
+		return err
+	}
+
+	return logError(ctx, s.repo.Delete(ctx, shortCode))
+}
+
+// Update перенаправляет shortURL на новый URL и возвращает обновленную ссылку
+func (s *URLService) Update(ctx context.Context, shortCode string, newURL string) (*repository.Link, error) {
+	// prepareURL может ходить в сеть, поэтому сначала проверяем владельца
+	if _, err := s.getOwned(ctx, shortCode); err != nil {
+		return nil, err
+	}
+
+	newURL, err := s.prepareURL(ctx, newURL, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Update(ctx, shortCode, newURL)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrURLShortened
+		}
+		return nil, logError(ctx, err)
+	}
+
+	link, err := s.repo.Get(ctx, shortCode)
+	if err != nil {
+		return nil, logError(ctx, err)
+	}
+
+	return link, nil
+}
+
+// List возвращает страницу ссылок, limit приводится к допустимому диапазону
+func (s *URLService) List(ctx context.Context, filter repository.ListFilter, cursor *repository.Cursor, limit int) (*repository.LinkPage, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	// обычный ключ видит только свои ссылки
+	if owner, all := s.caller(ctx); !all {
+		filter.Owner = owner
+	}
+
+	page, err := s.repo.List(ctx, filter, cursor, limit)
+	if err != nil {
+		return nil, logError(ctx, fmt.Errorf("failed to list URLs: %w", err))
+	}
+
+	return page, nil
+}
+
+// TracksClicks учитываются ли переходы по ссылкам
+func (s *URLService) TracksClicks() bool {
+	return s.tracker != nil
+}
+
+// TrackVisit учитывает переход по ссылке, не блокируя вызывающего
+func (s *URLService) TrackVisit(visit analytics.Visit) {
+	if s.tracker == nil {
+		return
+	}
+
+	if visit.Time.IsZero() {
+		visit.Time = s.now()
+	}
+	s.tracker.Record(visit)
+}
+
+// Stats возвращает статистику переходов по ссылке начиная с since
+func (s *URLService) Stats(ctx context.Context, shortCode string, since time.Time) (*repository.ClickStats, error) {
+	if s.tracker == nil {
+		return nil, ErrNoAnalytics
+	}
+
+	// статистику отдаем только по существующим ссылкам
+	if _, err := s.getOwned(ctx, shortCode); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.tracker.Stats(ctx, shortCode, since)
+	if err != nil {
+		return nil, logError(ctx, fmt.Errorf("failed to get click stats: %w", err))
+	}
+
+	return stats, nil
+}
+
+// caller владелец ключа из контекста. all означает доступ ко всем ссылкам:
+// у администратора и когда аутентификация выключена
+func (s *URLService) caller(ctx context.Context) (owner string, all bool) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", true
+	}
+	return principal.KeyID, principal.Admin
+}
+
+// getOwned получает ссылку, если она принадлежит вызывающему.
+// Чужие ссылки неотличимы от несуществующих
+func (s *URLService) getOwned(ctx context.Context, shortCode string) (*repository.Link, error) {
 	if !shortener.Validate(shortCode) {
-		return "", repository.ErrNotFound
+		return nil, repository.ErrNotFound
 	}
 
-	originalURL, err := s.repo.Get(ctx, shortCode)
+	link, err := s.repo.Get(ctx, shortCode)
 	if err != nil {
+		return nil, logError(ctx, err)
+	}
+
+	if owner, all := s.caller(ctx); !all && link.Owner != owner {
+		return nil, repository.ErrNotFound
+	}
+
+	return link, nil
+}
+
+// logError пишет неожиданную ошибку хранилища в лог вместе с request ID
+// из контекста и возвращает ее же. Отсутствие ссылки и отмена запроса
+// клиентом ошибками не считаются
+func logError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, repository.ErrNotFound) || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	slog.ErrorContext(ctx, "repository operation failed", "error", err)
+	return err
+}
+
+// expiresAt вычисляет момент истечения ссылки из опций
+func (s *URLService) expiresAt(opts CreateOptions) (*time.Time, error) {
+	if opts.ExpiresAt != nil && opts.TTL != 0 {
+		return nil, ErrInvalidTTL
+	}
+
+	if opts.TTL < 0 || opts.TTL > MaxTTL {
+		return nil, ErrInvalidTTL
+	}
+	if opts.TTL > 0 {
+		expiresAt := s.now().Add(opts.TTL)
+		return &expiresAt, nil
+	}
+
+	if opts.ExpiresAt != nil && (!opts.ExpiresAt.After(s.now()) || opts.ExpiresAt.After(s.now().Add(MaxTTL))) {
+		return nil, ErrInvalidTTL
+	}
+
+	return opts.ExpiresAt, nil
+}
+
+// prepareURL проверяет URL и приводит его к каноническому виду,
+// дедупликация, генерация кода и политика работают уже с ним. updating код
+// ссылки, которую перенаправляют на rawURL, пустой при создании
+func (s *URLService) prepareURL(ctx context.Context, rawURL, updating string) (string, error) {
+	if err := s.validateURL(rawURL); err != nil {
 		return "", err
 	}
 
-	return originalURL, nil
+	if s.normalize != nil {
+		normalized, err := s.normalize(rawURL)
+		if err != nil {
+			return "", ErrInvalidURL
+		}
+		rawURL = normalized
+	}
+
+	if s.guard != nil {
+		// петля ищется по ссылкам такими, какими они станут: обновляемая
+		// уже ведет на новый адрес, а не на текущий
+		lookup := s.lookupTarget
+		if updating != "" {
+			newURL := rawURL
+			lookup = func(ctx context.Context, shortCode string) (string, bool, error) {
+				if shortCode == updating {
+					return newURL, true, nil
+				}
+				return s.lookupTarget(ctx, shortCode)
+			}
+		}
+
+		target, err := s.guard.Check(ctx, rawURL, lookup)
+		if err != nil {
+			return "", err
+		}
+		rawURL = target
+	}
+
+	if s.policy != nil {
+		if err := s.policy.Check(ctx, rawURL); err != nil {
+			return "", err
+		}
+	}
+
+	return rawURL, nil
+}
+
+// lookupTarget адрес нашей ссылки для loopguard, истекшие считаются отсутствующими
+func (s *URLService) lookupTarget(ctx context.Context, shortCode string) (string, bool, error) {
+	target, err := s.Resolve(ctx, shortCode)
+	switch {
+	case err == nil:
+		return target, true, nil
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, ErrExpired):
+		return "", false, nil
+	default:
+		return "", false, err
+	}
 }
 
 // validateURL проверяем валидность URL
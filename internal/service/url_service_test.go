@@ -2,9 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
+	"shortURL/internal/auth"
+	"shortURL/internal/loopguard"
+	"shortURL/internal/repository"
 	"shortURL/internal/repository/memory"
+	"shortURL/pkg/shortener"
+	"shortURL/pkg/urlnorm"
 )
 
 func TestURLService_Create(t *testing.T) {
@@ -206,3 +215,603 @@ func TestURLService_MultipleDifferentURLs(t *testing.T) {
 		t.Errorf("Resolve() url2 = %s, want %s", resolved2, url2)
 	}
 }
+
+func TestURLService_CreateCollision(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	service := NewURLService(repo)
+	ctx := context.Background()
+
+	// занимаем коды, которые stub вернет на первых попытках
+	for i := 0; i < 3; i++ {
+		code := fmt.Sprintf("collide%03d", i)
+		if err := repo.Save(ctx, &repository.Link{ShortCode: code, OriginalURL: fmt.Sprintf("https://other.com/%d", i)}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	service.generator = shortener.GeneratorFunc(func(ctx context.Context, originalURL string, attempt int) (string, error) {
+		return fmt.Sprintf("collide%03d", attempt), nil
+	})
+
+	url := "https://example.com/test"
+	shortCode, err := service.Create(ctx, url)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if shortCode != "collide003" {
+		t.Errorf("Create() = %s, want collide003", shortCode)
+	}
+
+	resolvedURL, err := service.Resolve(ctx, shortCode)
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if resolvedURL != url {
+		t.Errorf("Resolve() = %s, want %s", resolvedURL, url)
+	}
+
+	// повторный вызов возвращает тот же код
+	again, err := service.Create(ctx, url)
+	if err != nil {
+		t.Fatalf("Create() second call failed: %v", err)
+	}
+	if again != shortCode {
+		t.Errorf("Create() not idempotent after collision: got %s and %s", shortCode, again)
+	}
+}
+
+func TestURLService_CreateCollisionExhausted(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	service := NewURLService(repo)
+	ctx := context.Background()
+
+	if err := repo.Save(ctx, &repository.Link{ShortCode: "collide000", OriginalURL: "https://other.com"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	service.generator = shortener.GeneratorFunc(func(ctx context.Context, originalURL string, attempt int) (string, error) {
+		return "collide000", nil
+	})
+
+	_, err := service.Create(ctx, "https://example.com/test")
+	if !errors.Is(err, ErrCollision) {
+		t.Errorf("Create() error = %v, want %v", err, ErrCollision)
+	}
+}
+
+func TestURLService_CreateWithAlias(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	service := NewURLService(repo)
+	ctx := context.Background()
+
+	url := "https://example.com/sale"
+	shortCode, err := service.CreateWithOptions(ctx, url, CreateOptions{Alias: "spring-sale"})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() failed: %v", err)
+	}
+	if shortCode != "spring-sale" {
+		t.Errorf("CreateWithOptions() = %s, want spring-sale", shortCode)
+	}
+
+	resolvedURL, err := service.Resolve(ctx, "spring-sale")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if resolvedURL != url {
+		t.Errorf("Resolve() = %s, want %s", resolvedURL, url)
+	}
+
+	// повторная резервация тем же URL идемпотентна
+	if _, err := service.CreateWithOptions(ctx, url, CreateOptions{Alias: "spring-sale"}); err != nil {
+		t.Errorf("CreateWithOptions() same alias failed: %v", err)
+	}
+
+	// но с другими параметрами не молча игнорирует их
+	until := time.Now().Add(time.Hour)
+	for _, opts := range []CreateOptions{
+		{Alias: "spring-sale", RedirectStatus: http.StatusTemporaryRedirect},
+		{Alias: "spring-sale", ExpiresAt: &until},
+	} {
+		if _, err := service.CreateWithOptions(ctx, url, opts); !errors.Is(err, ErrOptionsConflict) {
+			t.Errorf("CreateWithOptions(%+v) error = %v, want %v", opts, err, ErrOptionsConflict)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		alias       string
+		expectedErr error
+	}{
+		{
+			name:        "alias taken by another URL",
+			url:         "https://example.com/other",
+			alias:       "spring-sale",
+			expectedErr: ErrAliasTaken,
+		},
+		{
+			name:        "URL already has another code",
+			url:         url,
+			alias:       "summer-sale",
+			expectedErr: ErrURLShortened,
+		},
+		{
+			name:        "invalid alias characters",
+			url:         "https://example.com/x",
+			alias:       "bad/alias",
+			expectedErr: ErrInvalidAlias,
+		},
+		{
+			name:        "reserved alias",
+			url:         "https://example.com/x",
+			alias:       "shorten",
+			expectedErr: ErrInvalidAlias,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateWithOptions(ctx, tt.url, CreateOptions{Alias: tt.alias})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("CreateWithOptions() error = %v, want %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestURLService_CreateWithExpiration(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	service := NewURLService(repo)
+	ctx := context.Background()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	shortCode, err := service.CreateWithOptions(ctx, "https://example.com/ttl", CreateOptions{TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() failed: %v", err)
+	}
+
+	link, err := repo.Get(ctx, shortCode)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if link.ExpiresAt == nil || !link.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("ExpiresAt = %v, want %v", link.ExpiresAt, now.Add(time.Hour))
+	}
+
+	if _, err := service.Resolve(ctx, shortCode); err != nil {
+		t.Errorf("Resolve() before expiration failed: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := service.Resolve(ctx, shortCode); !errors.Is(err, ErrExpired) {
+		t.Errorf("Resolve() error = %v, want %v", err, ErrExpired)
+	}
+
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	tooFar := now.Add(MaxTTL + time.Hour)
+	invalid := []CreateOptions{
+		{TTL: -time.Second},
+		{TTL: MaxTTL + time.Second},
+		{ExpiresAt: &past},
+		{ExpiresAt: &tooFar},
+		{ExpiresAt: &future, TTL: time.Minute},
+	}
+	for _, opts := range invalid {
+		if _, err := service.CreateWithOptions(ctx, "https://example.com/bad", opts); !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("CreateWithOptions(%+v) error = %v, want %v", opts, err, ErrInvalidTTL)
+		}
+	}
+
+	// уже сокращенный URL отдается, только если срок жизни тот же.
+	// Хранилище проверяет истечение по настоящим часам
+	service.now = time.Now
+	until := time.Now().Add(time.Hour)
+	code, err := service.CreateWithOptions(ctx, "https://example.com/until", CreateOptions{ExpiresAt: &until})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() failed: %v", err)
+	}
+	if again, err := service.CreateWithOptions(ctx, "https://example.com/until", CreateOptions{ExpiresAt: &until}); err != nil || again != code {
+		t.Errorf("CreateWithOptions() same expiry = %q, %v, want %q", again, err, code)
+	}
+	later := until.Add(time.Hour)
+	for _, opts := range []CreateOptions{{}, {ExpiresAt: &later}} {
+		if _, err := service.CreateWithOptions(ctx, "https://example.com/until", opts); !errors.Is(err, ErrOptionsConflict) {
+			t.Errorf("CreateWithOptions(%+v) error = %v, want %v", opts, err, ErrOptionsConflict)
+		}
+	}
+}
+
+func TestURLService_OwnerScope(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	service := NewURLService(repo)
+
+	alice := auth.WithPrincipal(context.Background(), &auth.Principal{KeyID: "alice"})
+	bob := auth.WithPrincipal(context.Background(), &auth.Principal{KeyID: "bob"})
+	admin := auth.WithPrincipal(context.Background(), &auth.Principal{KeyID: auth.AdminKeyID, Admin: true})
+
+	shortCode, err := service.Create(alice, "https://example.com/alice")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if link, _ := repo.Get(context.Background(), shortCode); link.Owner != "alice" {
+		t.Errorf("Owner = %q, want %q", link.Owner, "alice")
+	}
+	_, _ = service.Create(bob, "https://example.com/bob")
+
+	page, err := service.List(alice, repository.ListFilter{}, nil, 0)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(page.Links) != 1 || page.Links[0].ShortCode != shortCode {
+		t.Errorf("List() for owner = %v, want only %s", page.Links, shortCode)
+	}
+	if page, _ := service.List(admin, repository.ListFilter{}, nil, 0); len(page.Links) != 2 {
+		t.Errorf("List() for admin returned %d links, want 2", len(page.Links))
+	}
+
+	// чужая ссылка выглядит как несуществующая
+	if _, err := service.Update(bob, shortCode, "https://example.com/hijack"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Update() by other owner error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if err := service.Delete(bob, shortCode); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Delete() by other owner error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	// тот же URL у другого владельца получает свой код, а не чужой
+	bobCode, err := service.Create(bob, "https://example.com/alice")
+	if err != nil {
+		t.Fatalf("Create() same URL by other owner failed: %v", err)
+	}
+	if bobCode == shortCode {
+		t.Errorf("Create() by other owner returned code %s of alice", shortCode)
+	}
+	if err := service.Delete(bob, bobCode); err != nil {
+		t.Errorf("Delete() own code failed: %v", err)
+	}
+
+	if _, err := service.Update(alice, shortCode, "https://example.com/alice2"); err != nil {
+		t.Errorf("Update() by owner failed: %v", err)
+	}
+	if err := service.Delete(admin, shortCode); err != nil {
+		t.Errorf("Delete() by admin failed: %v", err)
+	}
+}
+
+func TestURLService_Normalization(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	service := NewURLService(repo, WithNormalization(urlnorm.Options{DropFragment: true}))
+	ctx := context.Background()
+
+	first, err := service.Create(ctx, "HTTP://Example.com:80/a")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	for _, url := range []string{"http://example.com/a", "http://example.com/a#frag", "http://example.com/b/../a"} {
+		code, err := service.Create(ctx, url)
+		if err != nil {
+			t.Fatalf("Create(%q) failed: %v", url, err)
+		}
+		if code != first {
+			t.Errorf("Create(%q) = %s, want %s", url, code, first)
+		}
+	}
+
+	resolved, err := service.Resolve(ctx, first)
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if resolved != "http://example.com/a" {
+		t.Errorf("Resolve() = %q, want normalized URL", resolved)
+	}
+
+	// без нормализации это разные ссылки
+	plain := NewURLService(memory.NewMemoryRepository())
+	a, _ := plain.Create(ctx, "HTTP://Example.com:80/a")
+	b, _ := plain.Create(ctx, "http://example.com/a")
+	if a == b {
+		t.Errorf("codes are equal without normalization: %s", a)
+	}
+}
+
+func TestURLService_SelfReference(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	guard, err := loopguard.New(loopguard.Config{
+		SelfHosts: []string{"sho.rt"},
+		Mode:      loopguard.ModeResolve,
+		MaxHops:   5,
+	}, nil)
+	if err != nil {
+		t.Fatalf("loopguard.New() failed: %v", err)
+	}
+	service := NewURLService(repo, WithLoopGuard(guard))
+	ctx := context.Background()
+
+	code, err := service.Create(ctx, "https://example.com/page")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// ссылка на нашу же ссылку дает тот же код
+	again, err := service.Create(ctx, "https://sho.rt/"+code)
+	if err != nil {
+		t.Fatalf("Create() of own short URL failed: %v", err)
+	}
+	if again != code {
+		t.Errorf("Create() = %s, want %s", again, code)
+	}
+
+	if _, err := service.Create(ctx, "https://sho.rt/nothere"); !errors.Is(err, ErrSelfReference) {
+		t.Errorf("Create() = %v, want %v", err, ErrSelfReference)
+	}
+
+	// ссылка на саму себя стала бы петлей
+	if _, err := service.Update(ctx, code, "https://sho.rt/"+code); !errors.Is(err, ErrRedirectLoop) {
+		t.Errorf("Update() to itself error = %v, want %v", err, ErrRedirectLoop)
+	}
+	if link, _ := repo.Get(ctx, code); link.OriginalURL != "https://example.com/page" {
+		t.Errorf("OriginalURL = %q after update to itself", link.OriginalURL)
+	}
+}
+
+// redirectStub отвечает на HEAD редиректами из карты, без сети
+type redirectStub map[string]string
+
+func (r redirectStub) Do(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}
+	if location, ok := r[req.URL.String()]; ok {
+		resp.StatusCode = http.StatusMovedPermanently
+		resp.Header.Set("Location", location)
+	}
+	return resp, nil
+}
+
+func TestURLService_UpdateLoop(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	ctx := context.Background()
+
+	code := "loopcode01"
+	_ = repo.Save(ctx, &repository.Link{ShortCode: code, OriginalURL: "https://example.com/page"})
+
+	// bit.ly/y ведет на саму ссылку, которую перенаправляют на bit.ly/y
+	guard, err := loopguard.New(loopguard.Config{
+		SelfHosts:        []string{"sho.rt"},
+		ShortenerHosts:   []string{"bit.ly"},
+		FollowShorteners: true,
+		Mode:             loopguard.ModeResolve,
+		MaxHops:          5,
+	}, redirectStub{"https://bit.ly/y": "https://sho.rt/" + code})
+	if err != nil {
+		t.Fatalf("loopguard.New() failed: %v", err)
+	}
+	service := NewURLService(repo, WithLoopGuard(guard))
+
+	if _, err := service.Update(ctx, code, "https://bit.ly/y"); !errors.Is(err, ErrRedirectLoop) {
+		t.Errorf("Update() error = %v, want %v", err, ErrRedirectLoop)
+	}
+
+	// создать новую ссылку на bit.ly/y можно: цепочка кончается на example.com
+	if _, err := service.Create(ctx, "https://bit.ly/y"); err != nil {
+		t.Errorf("Create() failed: %v", err)
+	}
+}
+
+// countingDoer считает исходящие запросы
+type countingDoer struct {
+	requests int
+}
+
+func (d *countingDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests++
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+}
+
+func TestURLService_UpdateNotOwned(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	ctx := context.Background()
+
+	_ = repo.Save(ctx, &repository.Link{ShortCode: "alicecode1", OriginalURL: "https://example.com/alice", Owner: "alice"})
+
+	doer := &countingDoer{}
+	guard, err := loopguard.New(loopguard.Config{
+		ShortenerHosts:   []string{"bit.ly"},
+		FollowShorteners: true,
+		Mode:             loopguard.ModeResolve,
+		MaxHops:          5,
+	}, doer)
+	if err != nil {
+		t.Fatalf("loopguard.New() failed: %v", err)
+	}
+	service := NewURLService(repo, WithLoopGuard(guard))
+
+	// чужой ключ не может заставить сервис ходить по внешним адресам
+	bob := auth.WithPrincipal(ctx, &auth.Principal{KeyID: "bob"})
+	if _, err := service.Update(bob, "alicecode1", "https://bit.ly/x"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Update() by other owner error = %v, want %v", err, repository.ErrNotFound)
+	}
+	if doer.requests != 0 {
+		t.Errorf("Update() by other owner made %d requests, want 0", doer.requests)
+	}
+}
+
+func TestURLService_Generators(t *testing.T) {
+	ctx := context.Background()
+
+	obfuscated, err := shortener.NewObfuscatedGenerator(memory.NewMemoryRepository(), "0123456789abcdef")
+	if err != nil {
+		t.Fatalf("NewObfuscatedGenerator() failed: %v", err)
+	}
+
+	repo := memory.NewMemoryRepository()
+	generators := map[string]shortener.Generator{
+		shortener.GeneratorRandom:     shortener.RandomGenerator{},
+		shortener.GeneratorSequential: shortener.NewSequentialGenerator(repo),
+		shortener.GeneratorObfuscated: obfuscated,
+	}
+
+	for name, generator := range generators {
+		t.Run(name, func(t *testing.T) {
+			service := NewURLService(repo, WithGenerator(generator))
+
+			url := "https://example.com/" + name
+			code, err := service.Create(ctx, url)
+			if err != nil {
+				t.Fatalf("Create() failed: %v", err)
+			}
+			if !shortener.Validate(code) {
+				t.Errorf("Create() = %q, not a valid short code", code)
+			}
+
+			// дедупликация по URL работает при любом генераторе
+			if again, err := service.Create(ctx, url); err != nil || again != code {
+				t.Errorf("Create() again = %q, %v, want %q", again, err, code)
+			}
+
+			if resolved, err := service.Resolve(ctx, code); err != nil || resolved != url {
+				t.Errorf("Resolve() = %q, %v, want %q", resolved, err, url)
+			}
+		})
+	}
+
+	failing := NewURLService(repo, WithGenerator(shortener.GeneratorFunc(func(ctx context.Context, originalURL string, attempt int) (string, error) {
+		return "", errors.New("sequence unavailable")
+	})))
+	if _, err := failing.Create(ctx, "https://example.com/failing"); err == nil {
+		t.Error("Create() with failing generator succeeded")
+	}
+}
+
+func TestURLService_MistypedAlias(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewMemoryRepository()
+	service := NewURLService(repo)
+	format := shortener.CodeFormat{Length: 10, Alphabet: shortener.AlphabetDefault, Checksum: true}
+
+	// алиас длины кода с контрольным символом, созданный до его включения
+	var alias string
+	for _, last := range "abc" {
+		if err := shortener.SetCodeFormat(format); err != nil {
+			t.Fatalf("SetCodeFormat() failed: %v", err)
+		}
+		mistyped := shortener.Mistyped("summer2024" + string(last))
+		shortener.SetCodeFormat(shortener.DefaultCodeFormat)
+		if mistyped {
+			alias = "summer2024" + string(last)
+			break
+		}
+	}
+	if _, err := service.CreateWithOptions(ctx, "https://example.com/summer", CreateOptions{Alias: alias}); err != nil {
+		t.Fatalf("CreateWithOptions() failed: %v", err)
+	}
+
+	shortener.SetCodeFormat(format)
+	defer shortener.SetCodeFormat(shortener.DefaultCodeFormat)
+
+	if url, err := service.Resolve(ctx, alias); err != nil || url != "https://example.com/summer" {
+		t.Errorf("Resolve(%q) = %q, %v, want existing alias", alias, url, err)
+	}
+	if _, err := service.Update(ctx, alias, "https://example.com/winter"); err != nil {
+		t.Errorf("Update(%q) failed: %v", alias, err)
+	}
+	if err := service.Delete(ctx, alias); err != nil {
+		t.Errorf("Delete(%q) failed: %v", alias, err)
+	}
+
+	// без алиаса тот же код снова считается опечаткой
+	if _, err := service.Resolve(ctx, alias); !errors.Is(err, ErrMistyped) {
+		t.Errorf("Resolve(%q) after Delete() error = %v, want %v", alias, err, ErrMistyped)
+	}
+}
+
+func TestURLService_WordFilter(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewMemoryRepository()
+	words := shortener.NewWordFilter([]string{"shit"})
+
+	// фильтр применяется и к генератору, заданному любой опцией
+	service := NewURLService(repo, WithWordFilter(words), WithGenerator(shortener.GeneratorFunc(
+		func(ctx context.Context, originalURL string, attempt int) (string, error) {
+			if attempt == 0 {
+				return "xxsh1txxxx", nil
+			}
+			return "cleancode1", nil
+		})))
+
+	code, err := service.Create(ctx, "https://example.com/generated")
+	if err != nil || code != "cleancode1" {
+		t.Errorf("Create() = %q, %v, want cleancode1", code, err)
+	}
+
+	for _, alias := range []string{"my-shit", "MY_SH1T"} {
+		_, err := service.CreateWithOptions(ctx, "https://example.com/"+alias, CreateOptions{Alias: alias})
+		if !errors.Is(err, ErrAliasBlocked) {
+			t.Errorf("CreateWithOptions(%q) error = %v, want ErrAliasBlocked", alias, err)
+		}
+	}
+
+	if _, err := service.CreateWithOptions(ctx, "https://example.com/ok", CreateOptions{Alias: "my-page"}); err != nil {
+		t.Errorf("CreateWithOptions() failed: %v", err)
+	}
+}
+
+func TestURLService_CreateBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewMemoryRepository()
+
+	// первая попытка для каждого URL дает один и тот же код
+	service := NewURLService(repo, WithGenerator(shortener.GeneratorFunc(
+		func(ctx context.Context, originalURL string, attempt int) (string, error) {
+			if attempt == 0 {
+				return "collision_", nil
+			}
+			return shortener.GenerateAttempt(originalURL, attempt), nil
+		})))
+
+	existing, err := service.CreateWithOptions(ctx, "https://example.com/existing", CreateOptions{Alias: "existing"})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() failed: %v", err)
+	}
+
+	items := []BatchItem{
+		{URL: "https://example.com/1"},
+		{URL: "https://example.com/2"},
+		{URL: "not a url"},
+		{URL: "https://example.com/existing"},
+		{URL: "https://example.com/alias", Options: CreateOptions{Alias: "my-alias"}},
+		{URL: "https://example.com/taken", Options: CreateOptions{Alias: "existing"}},
+		{URL: "https://example.com/1"},
+		{URL: "https://example.com/status", Options: CreateOptions{RedirectStatus: 303}},
+	}
+
+	results, err := service.CreateBatch(ctx, items)
+	if err != nil {
+		t.Fatalf("CreateBatch() failed: %v", err)
+	}
+
+	wantErrs := []error{nil, nil, ErrInvalidURL, nil, nil, ErrAliasTaken, nil, ErrRedirectCode}
+	for i, result := range results {
+		if !errors.Is(result.Err, wantErrs[i]) || (wantErrs[i] == nil && result.Err != nil) {
+			t.Errorf("results[%d].Err = %v, want %v", i, result.Err, wantErrs[i])
+		}
+	}
+
+	if results[0].ShortCode != "collision_" || results[1].ShortCode == "collision_" || results[1].ShortCode == "" {
+		t.Errorf("generated codes = %q, %q, want collision resolved", results[0].ShortCode, results[1].ShortCode)
+	}
+	if results[3].ShortCode != existing || results[4].ShortCode != "my-alias" || results[6].ShortCode != results[0].ShortCode {
+		t.Errorf("results = %+v", results)
+	}
+
+	for _, i := range []int{0, 1, 4} {
+		if url, err := service.Resolve(ctx, results[i].ShortCode); err != nil || url != items[i].URL {
+			t.Errorf("Resolve(%s) = %q, %v, want %q", results[i].ShortCode, url, err, items[i].URL)
+		}
+	}
+
+	if _, err := service.CreateBatch(ctx, make([]BatchItem, MaxBatchSize+1)); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("CreateBatch() error = %v, want ErrBatchTooLarge", err)
+	}
+}
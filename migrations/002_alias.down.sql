@@ -0,0 +1,10 @@
+-- длинные алиасы в VARCHAR(10) не влезут, удалять их молча нельзя
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM urls WHERE length(short_code) > 10) THEN
+        RAISE EXCEPTION 'cannot revert 002_alias: short codes longer than 10 characters exist';
+    END IF;
+END
+$$;
+
+ALTER TABLE urls ALTER COLUMN short_code TYPE VARCHAR(10);
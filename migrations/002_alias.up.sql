@@ -0,0 +1 @@
+ALTER TABLE urls ALTER COLUMN short_code TYPE VARCHAR(64);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_expires_at;
+
+ALTER TABLE urls DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_created_at_id ON urls(created_at DESC, id DESC);
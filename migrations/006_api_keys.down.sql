@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_owner_created_at_id;
+
+-- не сработает, если один URL уже сокращен разными владельцами
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_owner_original_url_key;
+ALTER TABLE urls ADD CONSTRAINT urls_original_url_key UNIQUE (original_url);
+
+ALTER TABLE urls DROP COLUMN IF EXISTS owner;
+
+DROP TABLE IF EXISTS api_keys;
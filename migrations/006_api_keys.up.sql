@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(32) PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    secret_hash VARCHAR(64) NOT NULL,
+    admin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS owner VARCHAR(32) NOT NULL DEFAULT '';
+
+-- URL уникален в пределах владельца, иначе один ключ получал бы код другого
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_original_url_key;
+ALTER TABLE urls ADD CONSTRAINT urls_owner_original_url_key UNIQUE (owner, original_url);
+
+CREATE INDEX IF NOT EXISTS idx_owner_created_at_id ON urls(owner, created_at DESC, id DESC);
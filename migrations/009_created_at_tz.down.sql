@@ -0,0 +1 @@
+ALTER TABLE urls ALTER COLUMN created_at TYPE TIMESTAMP USING created_at::timestamp;
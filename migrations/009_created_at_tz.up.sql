@@ -0,0 +1,2 @@
+-- NOW() записывал в created_at время в зоне сессии, в ней же его и читаем
+ALTER TABLE urls ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at::timestamptz;
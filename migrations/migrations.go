@@ -0,0 +1,8 @@
+// Package migrations SQL миграции схемы postgres, вшитые в бинарник.
+// Файлы называются NNN_name.up.sql и NNN_name.down.sql
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
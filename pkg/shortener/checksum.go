@@ -0,0 +1,73 @@
+package shortener
+
+import "strings"
+
+// checkChar контрольный символ Luhn mod N: ловит любую замену одного
+// символа и почти все перестановки соседних
+func checkChar(code, alphabet string) byte {
+	n := len(alphabet)
+	factor, sum := 2, 0
+
+	for i := len(code) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(alphabet, code[i])
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+
+	return alphabet[(n-sum%n)%n]
+}
+
+// validCheck совпадает ли последний символ кода с контрольным
+func validCheck(code, alphabet string) bool {
+	last := len(code) - 1
+	return last > 0 && code[last] == checkChar(code[:last], alphabet)
+}
+
+// Mistyped похож ли код на сгенерированный с контрольным символом, но
+// символ не сходится. Сгенерирован такой код быть не мог, открыться он
+// может, только если это алиас
+func Mistyped(shortCode string) bool {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	mistyped := false
+	for _, format := range acceptedFormats {
+		if format.Validate(shortCode) {
+			return false
+		}
+		if format.Checksum && format.shaped(shortCode) {
+			mistyped = true
+		}
+	}
+
+	return mistyped
+}
+
+// Suggest коды с верным контрольным символом, которые получаются из
+// shortCode перестановкой двух соседних символов
+func Suggest(shortCode string) []string {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	var suggestions []string
+	seen := make(map[string]bool)
+
+	for i := 0; i+1 < len(shortCode); i++ {
+		if shortCode[i] == shortCode[i+1] {
+			continue
+		}
+
+		swapped := []byte(shortCode)
+		swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+		candidate := string(swapped)
+
+		for _, format := range acceptedFormats {
+			if format.Checksum && format.Validate(candidate) && !seen[candidate] {
+				seen[candidate] = true
+				suggestions = append(suggestions, candidate)
+			}
+		}
+	}
+
+	return suggestions
+}
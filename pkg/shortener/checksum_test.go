@@ -0,0 +1,92 @@
+package shortener
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCheckChar(t *testing.T) {
+	// пример Luhn mod N из описания алгоритма
+	if got := checkChar("abcdef", "abcdef"); got != 'e' {
+		t.Errorf("checkChar() = %c, want e", got)
+	}
+
+	code := "q8Zk2mPw3x"
+	sealed := code + string(checkChar(code, AlphabetBase62))
+	if !validCheck(sealed, AlphabetBase62) {
+		t.Fatalf("validCheck(%q) = false", sealed)
+	}
+
+	// любая замена одного символа ловится
+	for i := 0; i < len(sealed); i++ {
+		for j := 0; j < len(AlphabetBase62); j++ {
+			if AlphabetBase62[j] == sealed[i] {
+				continue
+			}
+			typo := []byte(sealed)
+			typo[i] = AlphabetBase62[j]
+			if validCheck(string(typo), AlphabetBase62) {
+				t.Fatalf("validCheck(%q) = true for typo", typo)
+			}
+		}
+	}
+}
+
+func TestChecksumFormat(t *testing.T) {
+	defer SetCodeFormat(DefaultCodeFormat)
+
+	format := CodeFormat{Length: 8, Alphabet: AlphabetFriendly, Checksum: true}
+	if err := SetCodeFormat(format); err != nil {
+		t.Fatalf("SetCodeFormat() failed: %v", err)
+	}
+	ctx := context.Background()
+
+	codes := []string{Generate("https://example.com")}
+	random, _ := RandomGenerator{}.Generate(ctx, "", 0)
+	codes = append(codes, random)
+
+	seq := &counter{}
+	obfuscated, _ := NewObfuscatedGenerator(seq, "0123456789abcdef")
+	for i := 0; i < 5; i++ {
+		code, err := obfuscated.Generate(ctx, "", 0)
+		if err != nil {
+			t.Fatalf("Generate() failed: %v", err)
+		}
+		if n, err := obfuscated.Decode(code); err != nil || n != uint64(i+1) {
+			t.Errorf("Decode(%q) = %d, %v, want %d", code, n, err, i+1)
+		}
+		codes = append(codes, code)
+	}
+
+	for _, code := range codes {
+		if len(code) != 9 || !Validate(code) || Mistyped(code) {
+			t.Errorf("code %q is not a valid 9 character code", code)
+		}
+	}
+
+	code := codes[0]
+	typo := code[:2] + string(code[3]) + string(code[2]) + code[4:]
+	if code[2] == code[3] || Validate(typo) {
+		t.Skipf("transposition of %q not detected by check character", code)
+	}
+	if !Mistyped(typo) {
+		t.Errorf("Mistyped(%q) = false", typo)
+	}
+	if !slices.Contains(Suggest(typo), code) {
+		t.Errorf("Suggest(%q) = %v, want %q among them", typo, Suggest(typo), code)
+	}
+
+	// алиас в форме кода обязан иметь верный контрольный символ
+	if ValidateAlias(typo) {
+		t.Errorf("ValidateAlias(%q) = true", typo)
+	}
+	if !ValidateAlias("my-campaign") || Mistyped("my-campaign") {
+		t.Error("ordinary alias rejected")
+	}
+
+	// коды формата по умолчанию продолжают открываться
+	if !Validate("abcdefghij") {
+		t.Error("default format code rejected")
+	}
+}
@@ -0,0 +1,238 @@
+package shortener
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrCodeRejected = errors.New("all generated codes contain blocked words")
+
+const (
+	// minWordLength более короткие слова встречаются в случайных кодах
+	// слишком часто, чтобы их отсеивать
+	minWordLength = 3
+
+	// maxFilterAttempts сколько раз перегенерируем код, прежде чем сдаться
+	maxFilterAttempts = 16
+
+	// filterAttemptStep сдвиг attempt при перегенерации, чтобы не пересекаться
+	// с попытками, которые сервис делает при коллизиях
+	filterAttemptStep = 1 << 16
+)
+
+// leetspeak буквы, которые могут скрываться за символом кода
+var leetspeak = map[byte]string{
+	'0': "o",
+	'1': "il",
+	'2': "z",
+	'3': "e",
+	'4': "a",
+	'5': "s",
+	'6': "g",
+	'7': "t",
+	'8': "b",
+	'9': "g",
+}
+
+// cyrillic транслит кириллицы: в коде такие слова могут встретиться
+// только латиницей
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "h", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "",
+	'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+}
+
+// WordFilter находит в кодах слова из списка, в том числе записанные
+// цифрами вместо букв и через разделители: a55, s_h_1_t
+type WordFilter struct {
+	words   []string
+	ignored []string
+}
+
+// NewWordFilter собирает фильтр из слов. Регистр не важен, кириллица
+// переводится в латиницу. Остальные слова не из латиницы в коде
+// встретиться не могут, они пропускаются и доступны через Ignored
+func NewWordFilter(words []string) *WordFilter {
+	seen := make(map[string]bool)
+	f := &WordFilter{}
+
+	for _, raw := range words {
+		raw = strings.TrimSpace(raw)
+		word := transliterate(strings.ToLower(raw))
+		if !isLatin(word) {
+			f.ignored = append(f.ignored, raw)
+			continue
+		}
+		if len(word) < minWordLength || seen[word] {
+			continue
+		}
+		seen[word] = true
+		f.words = append(f.words, word)
+	}
+	sort.Strings(f.words)
+
+	return f
+}
+
+// transliterate заменяет кириллицу латиницей, остальное оставляет как есть
+func transliterate(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		if latin, ok := cyrillic[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// LoadWordFilter читает слова из файлов, по одному в строке, # начинает
+// комментарий. Каталог означает все *.txt в нем, например по файлу на язык
+func LoadWordFilter(paths ...string) (*WordFilter, error) {
+	var words []string
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read word list: %w", err)
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.txt")); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			fileWords, err := readWords(file)
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, fileWords...)
+		}
+	}
+
+	return NewWordFilter(words), nil
+}
+
+func readWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read word list: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			words = append(words, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read word list %s: %w", path, err)
+	}
+
+	return words, nil
+}
+
+// Len сколько слов в фильтре
+func (f *WordFilter) Len() int {
+	return len(f.words)
+}
+
+// Ignored слова, которые не удалось записать латиницей
+func (f *WordFilter) Ignored() []string {
+	return f.ignored
+}
+
+// Contains есть ли в коде слово из списка
+func (f *WordFilter) Contains(code string) bool {
+	letters := lettersOf(code)
+
+	for _, word := range f.words {
+		for start := 0; start+len(word) <= len(letters); start++ {
+			if matchAt(letters[start:], word) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// lettersOf для каждого символа кода буквы, которыми он может быть прочитан.
+// Разделители пропускаются, чтобы s_h_i_t тоже находилось
+func lettersOf(code string) []string {
+	letters := make([]string, 0, len(code))
+	for i := 0; i < len(code); i++ {
+		ch := code[i]
+		switch {
+		case ch == '_' || ch == '-' || ch == '.' || ch == '~':
+			continue
+		case 'A' <= ch && ch <= 'Z':
+			letters = append(letters, string(ch+'a'-'A'))
+		case 'a' <= ch && ch <= 'z':
+			letters = append(letters, string(ch))
+		default:
+			letters = append(letters, leetspeak[ch])
+		}
+	}
+	return letters
+}
+
+func matchAt(letters []string, word string) bool {
+	for i := 0; i < len(word); i++ {
+		if strings.IndexByte(letters[i], word[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isLatin(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// FilteredGenerator отбрасывает коды со словами из фильтра. Новый код
+// просим у next с attempt, сдвинутым на filterAttemptStep, поэтому хеш
+// для того же URL и попытки всегда дает один и тот же результат
+type FilteredGenerator struct {
+	next   Generator
+	filter *WordFilter
+}
+
+func NewFilteredGenerator(next Generator, filter *WordFilter) *FilteredGenerator {
+	return &FilteredGenerator{next: next, filter: filter}
+}
+
+func (g *FilteredGenerator) Generate(ctx context.Context, originalURL string, attempt int) (string, error) {
+	for i := 0; i < maxFilterAttempts; i++ {
+		code, err := g.next.Generate(ctx, originalURL, attempt+i*filterAttemptStep)
+		if err != nil {
+			return "", err
+		}
+		if !g.filter.Contains(code) {
+			return code, nil
+		}
+	}
+
+	return "", ErrCodeRejected
+}
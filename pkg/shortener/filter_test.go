@@ -0,0 +1,102 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWordFilter_Contains(t *testing.T) {
+	f := NewWordFilter([]string{"Shit", "ass", "it", "Жопа", "σκατά"})
+
+	if f.Len() != 3 {
+		t.Errorf("Len() = %d, want 3: short words are skipped", f.Len())
+	}
+	if ignored := f.Ignored(); len(ignored) != 1 || ignored[0] != "σκατά" {
+		t.Errorf("Ignored() = %q, want [σκατά]", ignored)
+	}
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"xxSHITxx", true},
+		{"xxsh1txx", true},
+		{"a55kd9Lq", true},
+		{"s_h_i_t", true},
+		{"xxSh17", true},
+		{"xZH0PAx", true},
+		{"aXsXsX", false},
+		{"q8Zk2mPw", false},
+	}
+	for _, tt := range tests {
+		if got := f.Contains(tt.code); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLoadWordFilter(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "en.txt"), []byte("# english\nshit\nass # short\n\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "ru.txt"), []byte("blyat\n"), 0o644)
+	extra := filepath.Join(t.TempDir(), "extra.list")
+	os.WriteFile(extra, []byte("crap\n"), 0o644)
+
+	f, err := LoadWordFilter(dir, extra)
+	if err != nil {
+		t.Fatalf("LoadWordFilter() failed: %v", err)
+	}
+	if f.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", f.Len())
+	}
+	if !f.Contains("xbly4tx") || !f.Contains("cr4pxx") {
+		t.Error("Contains() missed words from files")
+	}
+
+	if _, err := LoadWordFilter(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("LoadWordFilter() with missing file succeeded")
+	}
+}
+
+func TestFilteredGenerator(t *testing.T) {
+	ctx := context.Background()
+	var attempts []int
+	next := GeneratorFunc(func(ctx context.Context, originalURL string, attempt int) (string, error) {
+		attempts = append(attempts, attempt)
+		if len(attempts) < 3 {
+			return "xxsh1txx", nil
+		}
+		return "q8Zk2mPw", nil
+	})
+
+	g := NewFilteredGenerator(next, NewWordFilter([]string{"shit"}))
+	code, err := g.Generate(ctx, "https://example.com", 1)
+	if err != nil || code != "q8Zk2mPw" {
+		t.Fatalf("Generate() = %q, %v", code, err)
+	}
+	want := []int{1, 1 + filterAttemptStep, 1 + 2*filterAttemptStep}
+	for i := range want {
+		if attempts[i] != want[i] {
+			t.Errorf("attempts = %v, want %v", attempts, want)
+			break
+		}
+	}
+
+	// хеш перегенерируется детерминированно
+	hash := NewFilteredGenerator(HashGenerator{}, NewWordFilter([]string{Generate("https://example.com")[:4]}))
+	first, _ := hash.Generate(ctx, "https://example.com", 0)
+	second, _ := hash.Generate(ctx, "https://example.com", 0)
+	if first != second || first == Generate("https://example.com") {
+		t.Errorf("Generate() = %q, %q, want same regenerated code", first, second)
+	}
+
+	always := NewFilteredGenerator(GeneratorFunc(func(ctx context.Context, originalURL string, attempt int) (string, error) {
+		return "xxshitxx", nil
+	}), NewWordFilter([]string{"shit"}))
+	if _, err := always.Generate(ctx, "", 0); !errors.Is(err, ErrCodeRejected) {
+		t.Errorf("Generate() error = %v, want ErrCodeRejected", err)
+	}
+}
@@ -0,0 +1,208 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Алфавиты сгенерированных кодов
+const (
+	// AlphabetDefault символы, из которых коды состояли всегда: base64url с "_" вместо "-"
+	AlphabetDefault = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
+
+	// AlphabetBase62 цифры и латиница в обоих регистрах
+	AlphabetBase62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// AlphabetFriendly без похожих друг на друга 0/O и 1/l/I, для кодов,
+	// которые печатают или диктуют
+	AlphabetFriendly = "23456789abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// namedAlphabets алфавиты, которые в конфиге можно указать по имени
+var namedAlphabets = map[string]string{
+	"default":  AlphabetDefault,
+	"base62":   AlphabetBase62,
+	"friendly": AlphabetFriendly,
+}
+
+const (
+	// MinCodeLength и MaxCodeLength пределы длины кода, больше 32 символов
+	// хеш уже не дает новой энтропии
+	MinCodeLength = 4
+	MaxCodeLength = 32
+
+	// minAlphabetSize меньше символов дает слишком мало разных кодов
+	minAlphabetSize = 10
+)
+
+// CodeFormat длина и алфавит сгенерированных кодов
+type CodeFormat struct {
+	Length   int
+	Alphabet string
+
+	// Checksum дописывает к коду контрольный символ Luhn mod N, код
+	// становится на символ длиннее Length
+	Checksum bool
+}
+
+var DefaultCodeFormat = CodeFormat{
+	Length:   ShortURLLength,
+	Alphabet: AlphabetDefault,
+}
+
+var (
+	formatMu   sync.RWMutex
+	codeFormat = DefaultCodeFormat
+
+	// acceptedFormats форматы, коды которых считаются валидными: текущий,
+	// формат по умолчанию и прежние, чтобы старые ссылки продолжали работать
+	acceptedFormats = []CodeFormat{DefaultCodeFormat}
+)
+
+// Alphabet возвращает алфавит по имени или саму строку, если такого имени нет
+func Alphabet(name string) string {
+	if alphabet, ok := namedAlphabets[name]; ok {
+		return alphabet
+	}
+	return name
+}
+
+// SetCodeFormat меняет формат новых кодов, вызывается при старте.
+// previous форматы, в которых коды выдавались раньше
+func SetCodeFormat(current CodeFormat, previous ...CodeFormat) error {
+	for _, f := range append([]CodeFormat{current}, previous...) {
+		if err := f.Check(); err != nil {
+			return err
+		}
+	}
+	accepted := append([]CodeFormat{current, DefaultCodeFormat}, previous...)
+
+	formatMu.Lock()
+	defer formatMu.Unlock()
+	codeFormat = current
+	acceptedFormats = accepted
+
+	return nil
+}
+
+// CurrentCodeFormat формат, в котором генерируются новые коды
+func CurrentCodeFormat() CodeFormat {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	return codeFormat
+}
+
+// Validate подходит ли код под формат f, включая контрольный символ
+func (f CodeFormat) Validate(shortCode string) bool {
+	return f.shaped(shortCode) && (!f.Checksum || validCheck(shortCode, f.Alphabet))
+}
+
+// shaped совпадают ли длина и алфавит кода с форматом, без проверки
+// контрольного символа
+func (f CodeFormat) shaped(shortCode string) bool {
+	if len(shortCode) != f.codeLength() {
+		return false
+	}
+
+	for i := 0; i < len(shortCode); i++ {
+		if strings.IndexByte(f.Alphabet, shortCode[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// codeLength длина кода вместе с контрольным символом
+func (f CodeFormat) codeLength() int {
+	if f.Checksum {
+		return f.Length + 1
+	}
+	return f.Length
+}
+
+// seal дописывает контрольный символ, если формат его требует
+func (f CodeFormat) seal(code string) string {
+	if !f.Checksum {
+		return code
+	}
+	return code + string(checkChar(code, f.Alphabet))
+}
+
+// Check проверяет сам формат на корректность
+func (f CodeFormat) Check() error {
+	if f.Length < MinCodeLength || f.Length > MaxCodeLength {
+		return fmt.Errorf("code length must be between %d and %d", MinCodeLength, MaxCodeLength)
+	}
+	if len(f.Alphabet) < minAlphabetSize {
+		return fmt.Errorf("code alphabet must have at least %d characters", minAlphabetSize)
+	}
+
+	for i := 0; i < len(f.Alphabet); i++ {
+		ch := f.Alphabet[i]
+		if strings.IndexByte(aliasSafeChars, ch) < 0 {
+			return errors.New("code alphabet contains unsafe character: " + string(ch))
+		}
+		if strings.IndexByte(f.Alphabet[i+1:], ch) >= 0 {
+			return errors.New("code alphabet contains duplicate character: " + string(ch))
+		}
+	}
+
+	return nil
+}
+
+// space сколько разных чисел помещается в код. Ограничено 2^62, чтобы
+// перестановке хватало uint64
+func (f CodeFormat) space() uint64 {
+	const limit = 1 << 62
+
+	n := uint64(1)
+	for i := 0; i < f.Length; i++ {
+		if n > limit/uint64(len(f.Alphabet)) {
+			return limit
+		}
+		n *= uint64(len(f.Alphabet))
+	}
+	return n
+}
+
+// encode пишет n в системе счисления алфавита, дополняя первым символом
+// алфавита до длины кода
+func (f CodeFormat) encode(n uint64) (string, error) {
+	if n >= f.space() {
+		return "", ErrSequenceExhausted
+	}
+
+	base := uint64(len(f.Alphabet))
+	code := make([]byte, f.Length)
+	for i := len(code) - 1; i >= 0; i-- {
+		code[i] = f.Alphabet[n%base]
+		n /= base
+	}
+	return f.seal(string(code)), nil
+}
+
+// decode обратное к encode
+func (f CodeFormat) decode(code string) (uint64, error) {
+	if !f.Validate(code) {
+		return 0, ErrInvalidCode
+	}
+
+	code = code[:f.Length]
+	base := uint64(len(f.Alphabet))
+	var n uint64
+	for i := 0; i < len(code); i++ {
+		digit := uint64(strings.IndexByte(f.Alphabet, code[i]))
+		if n > (f.space()-digit)/base {
+			return 0, ErrInvalidCode
+		}
+		n = n*base + digit
+	}
+	if n >= f.space() {
+		return 0, ErrInvalidCode
+	}
+	return n, nil
+}
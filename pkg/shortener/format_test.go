@@ -0,0 +1,79 @@
+package shortener
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAlphabetFriendly(t *testing.T) {
+	for _, ch := range "0O1lI" {
+		if strings.ContainsRune(AlphabetFriendly, ch) {
+			t.Errorf("friendly alphabet contains look-alike %q", ch)
+		}
+	}
+	if err := (CodeFormat{Length: 8, Alphabet: AlphabetFriendly}).Check(); err != nil {
+		t.Errorf("Check() = %v", err)
+	}
+
+	if Alphabet("friendly") != AlphabetFriendly || Alphabet("abcdefghijk") != "abcdefghijk" {
+		t.Error("Alphabet() did not resolve names")
+	}
+}
+
+func TestCodeFormat_Check(t *testing.T) {
+	invalid := []CodeFormat{
+		{Length: 3, Alphabet: AlphabetBase62},
+		{Length: MaxCodeLength + 1, Alphabet: AlphabetBase62},
+		{Length: 8, Alphabet: "abcdef"},
+		{Length: 8, Alphabet: "abcdefghija"},
+		{Length: 8, Alphabet: "abcdefghij/"},
+	}
+	for _, f := range invalid {
+		if err := f.Check(); err == nil {
+			t.Errorf("Check(%+v) expected error, got nil", f)
+		}
+	}
+}
+
+func TestSetCodeFormat(t *testing.T) {
+	defer SetCodeFormat(DefaultCodeFormat)
+
+	ctx := context.Background()
+	oldHash := Generate("https://example.com")
+	previous := CodeFormat{Length: 6, Alphabet: AlphabetBase62}
+
+	if err := SetCodeFormat(CodeFormat{Length: 3, Alphabet: AlphabetBase62}); err == nil {
+		t.Error("SetCodeFormat() with invalid format succeeded")
+	}
+
+	friendly := CodeFormat{Length: 8, Alphabet: AlphabetFriendly}
+	if err := SetCodeFormat(friendly, previous); err != nil {
+		t.Fatalf("SetCodeFormat() failed: %v", err)
+	}
+
+	hash := Generate("https://example.com")
+	if !friendly.Validate(hash) || hash != Generate("https://example.com") {
+		t.Errorf("Generate() = %s, want deterministic friendly code", hash)
+	}
+	random, _ := RandomGenerator{}.Generate(ctx, "", 0)
+	if !friendly.Validate(random) {
+		t.Errorf("RandomGenerator = %s, want friendly code", random)
+	}
+
+	obfuscated, _ := NewObfuscatedGenerator(&counter{}, "0123456789abcdef")
+	code, _ := obfuscated.Generate(ctx, "", 0)
+	if n, err := obfuscated.Decode(code); !friendly.Validate(code) || err != nil || n != 1 {
+		t.Errorf("ObfuscatedGenerator = %s, Decode() = %d, %v", code, n, err)
+	}
+
+	// старые коды по умолчанию и прежнего формата продолжают открываться
+	for _, code := range []string{hash, oldHash, "aB3dE6", "abc_123_XY"} {
+		if !Validate(code) {
+			t.Errorf("Validate(%q) = false, want true", code)
+		}
+	}
+	if validateGenerated("aB3dE6g") {
+		t.Error("validateGenerated() accepted code of no known format")
+	}
+}
@@ -0,0 +1,207 @@
+package shortener
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Способы генерации кодов
+const (
+	GeneratorHash       = "hash"
+	GeneratorRandom     = "random"
+	GeneratorSequential = "sequential"
+	GeneratorObfuscated = "obfuscated"
+)
+
+// MinSecretLength минимальная длина ключа перестановки
+const MinSecretLength = 16
+
+var (
+	ErrSequenceExhausted = errors.New("sequence does not fit into short code")
+	ErrInvalidCode       = errors.New("invalid short code")
+)
+
+// Generator выдает коды для новых ссылок. attempt растет с каждой коллизией,
+// детерминированные генераторы по нему получают другой код
+type Generator interface {
+	Generate(ctx context.Context, originalURL string, attempt int) (string, error)
+}
+
+// GeneratorFunc позволяет использовать функцию как Generator
+type GeneratorFunc func(ctx context.Context, originalURL string, attempt int) (string, error)
+
+func (f GeneratorFunc) Generate(ctx context.Context, originalURL string, attempt int) (string, error) {
+	return f(ctx, originalURL, attempt)
+}
+
+// Sequence источник возрастающих номеров, обычно хранилище ссылок
+type Sequence interface {
+	NextSequence(ctx context.Context) (uint64, error)
+}
+
+// HashGenerator код из SHA-256 адреса: один URL всегда получает один код,
+// но код известного URL легко угадать
+type HashGenerator struct{}
+
+func (HashGenerator) Generate(ctx context.Context, originalURL string, attempt int) (string, error) {
+	return GenerateAttempt(originalURL, attempt), nil
+}
+
+// RandomGenerator код из crypto/rand в текущем формате
+type RandomGenerator struct{}
+
+func (RandomGenerator) Generate(ctx context.Context, originalURL string, attempt int) (string, error) {
+	format := CurrentCodeFormat()
+	code := make([]byte, format.Length)
+	buf := make([]byte, format.Length*2)
+
+	// отбрасываем верхние байты, чтобы остаток от деления на размер алфавита был равномерным
+	limit := 256 - 256%len(format.Alphabet)
+	for n := 0; n < len(code); {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			code[n] = format.Alphabet[int(b)%len(format.Alphabet)]
+			if n++; n == len(code) {
+				break
+			}
+		}
+	}
+
+	return format.seal(string(code)), nil
+}
+
+// SequentialGenerator номер из счетчика в системе счисления алфавита. Коды
+// без коллизий, но соседние ссылки перебираются подряд
+type SequentialGenerator struct {
+	seq    Sequence
+	format CodeFormat
+}
+
+// NewSequentialGenerator берет формат кодов, текущий на момент создания
+func NewSequentialGenerator(seq Sequence) *SequentialGenerator {
+	return &SequentialGenerator{seq: seq, format: CurrentCodeFormat()}
+}
+
+func (g *SequentialGenerator) Generate(ctx context.Context, originalURL string, attempt int) (string, error) {
+	n, err := g.seq.NextSequence(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next sequence: %w", err)
+	}
+	return g.format.encode(n)
+}
+
+// ObfuscatedGenerator номер из счетчика, пропущенный через перестановку
+// с ключом. Коды не повторяются, как у SequentialGenerator, но без ключа
+// по одному коду нельзя получить соседние
+type ObfuscatedGenerator struct {
+	seq    Sequence
+	format CodeFormat
+	perm   *permutation
+}
+
+// NewObfuscatedGenerator берет формат кодов, текущий на момент создания.
+// Смена формата или ключа меняет перестановку, номера старых кодов
+// после этого через Decode не восстановить
+func NewObfuscatedGenerator(seq Sequence, secret string) (*ObfuscatedGenerator, error) {
+	if len(secret) < MinSecretLength {
+		return nil, fmt.Errorf("secret must be at least %d characters", MinSecretLength)
+	}
+
+	format := CurrentCodeFormat()
+	return &ObfuscatedGenerator{
+		seq:    seq,
+		format: format,
+		perm:   newPermutation(secret, format.space()),
+	}, nil
+}
+
+func (g *ObfuscatedGenerator) Generate(ctx context.Context, originalURL string, attempt int) (string, error) {
+	n, err := g.seq.NextSequence(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next sequence: %w", err)
+	}
+	if n >= g.format.space() {
+		return "", ErrSequenceExhausted
+	}
+	return g.format.encode(g.perm.forward(n))
+}
+
+// Decode возвращает номер из счетчика, из которого получен код
+func (g *ObfuscatedGenerator) Decode(code string) (uint64, error) {
+	n, err := g.format.decode(code)
+	if err != nil {
+		return 0, err
+	}
+	return g.perm.backward(n), nil
+}
+
+// permutation биекция на [0, size): сеть Фейстеля на словах из четного
+// числа бит, значения за пределами size прогоняются повторно (cycle walking)
+type permutation struct {
+	size     uint64
+	halfBits uint
+	mask     uint64
+	keys     [feistelRounds]uint64
+}
+
+const feistelRounds = 4
+
+func newPermutation(secret string, size uint64) *permutation {
+	halfBits := uint(bits.Len64(size-1)+1) / 2
+	p := &permutation{
+		size:     size,
+		halfBits: halfBits,
+		mask:     1<<halfBits - 1,
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	for i := range p.keys {
+		p.keys[i] = binary.BigEndian.Uint64(sum[i*8:])
+	}
+	return p
+}
+
+func (p *permutation) forward(n uint64) uint64 {
+	for {
+		left, right := n>>p.halfBits, n&p.mask
+		for _, key := range p.keys {
+			left, right = right, left^p.round(right, key)
+		}
+		if n = left<<p.halfBits | right; n < p.size {
+			return n
+		}
+	}
+}
+
+func (p *permutation) backward(n uint64) uint64 {
+	for {
+		left, right := n>>p.halfBits, n&p.mask
+		for i := len(p.keys) - 1; i >= 0; i-- {
+			left, right = right^p.round(left, p.keys[i]), left
+		}
+		if n = left<<p.halfBits | right; n < p.size {
+			return n
+		}
+	}
+}
+
+// round перемешивает половину с ключом раунда (финализатор splitmix64)
+func (p *permutation) round(half, key uint64) uint64 {
+	x := half ^ key
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x & p.mask
+}
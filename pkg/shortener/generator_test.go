@@ -0,0 +1,151 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// counter Sequence в памяти
+type counter struct {
+	n atomic.Uint64
+}
+
+func (c *counter) NextSequence(ctx context.Context) (uint64, error) {
+	return c.n.Add(1), nil
+}
+
+func TestHashGenerator(t *testing.T) {
+	ctx := context.Background()
+	g := HashGenerator{}
+
+	code, _ := g.Generate(ctx, "https://example.com", 0)
+	if code != Generate("https://example.com") {
+		t.Errorf("Generate() = %s, want hash of URL", code)
+	}
+
+	salted, _ := g.Generate(ctx, "https://example.com", 1)
+	if salted == code {
+		t.Error("Generate() returned the same code for the next attempt")
+	}
+}
+
+func TestRandomGenerator(t *testing.T) {
+	ctx := context.Background()
+	seen := make(map[string]bool)
+
+	for i := 0; i < 1000; i++ {
+		code, err := RandomGenerator{}.Generate(ctx, "https://example.com", 0)
+		if err != nil {
+			t.Fatalf("Generate() failed: %v", err)
+		}
+		if !Validate(code) {
+			t.Fatalf("Generate() returned invalid short code: %s", code)
+		}
+		if seen[code] {
+			t.Fatalf("Generate() repeated code %s", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestSequentialGenerator(t *testing.T) {
+	defer SetCodeFormat(DefaultCodeFormat)
+	if err := SetCodeFormat(CodeFormat{Length: 6, Alphabet: AlphabetBase62}); err != nil {
+		t.Fatalf("SetCodeFormat() failed: %v", err)
+	}
+
+	ctx := context.Background()
+	g := NewSequentialGenerator(&counter{})
+
+	want := []string{"000001", "000002", "000003"}
+	for _, w := range want {
+		code, err := g.Generate(ctx, "https://example.com", 0)
+		if err != nil {
+			t.Fatalf("Generate() failed: %v", err)
+		}
+		if code != w {
+			t.Errorf("Generate() = %s, want %s", code, w)
+		}
+	}
+
+	seq := &counter{}
+	seq.n.Store(62*62 - 1)
+	if code, _ := NewSequentialGenerator(seq).Generate(ctx, "", 0); code != "000100" {
+		t.Errorf("Generate() = %s, want 000100", code)
+	}
+
+	seq.n.Store(62*62*62*62*62*62 - 1)
+	if _, err := NewSequentialGenerator(seq).Generate(ctx, "", 0); !errors.Is(err, ErrSequenceExhausted) {
+		t.Errorf("Generate() past code space = %v, want %v", err, ErrSequenceExhausted)
+	}
+}
+
+func TestObfuscatedGenerator(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := NewObfuscatedGenerator(&counter{}, "short"); err == nil {
+		t.Error("NewObfuscatedGenerator() with short secret succeeded")
+	}
+
+	g, err := NewObfuscatedGenerator(&counter{}, "0123456789abcdef")
+	if err != nil {
+		t.Fatalf("NewObfuscatedGenerator() failed: %v", err)
+	}
+	other, _ := NewObfuscatedGenerator(&counter{}, "fedcba9876543210")
+
+	seen := make(map[string]bool)
+	prev := ""
+	ascending := 0
+	for n := uint64(1); n <= 1000; n++ {
+		code, err := g.Generate(ctx, "", 0)
+		if err != nil {
+			t.Fatalf("Generate() failed: %v", err)
+		}
+		if !Validate(code) || seen[code] {
+			t.Fatalf("Generate() = %s, invalid or repeated", code)
+		}
+		seen[code] = true
+
+		if decoded, err := g.Decode(code); err != nil || decoded != n {
+			t.Fatalf("Decode(%s) = %d, %v, want %d", code, decoded, err, n)
+		}
+		if otherCode, _ := other.Generate(ctx, "", 0); otherCode == code {
+			t.Errorf("codes for %d are equal with different secrets", n)
+		}
+
+		if code > prev {
+			ascending++
+		}
+		prev = code
+	}
+
+	// соседние номера не должны давать возрастающие коды
+	if ascending > 600 {
+		t.Errorf("%d of 1000 codes are ascending, want about half", ascending)
+	}
+
+	if _, err := g.Decode("not-a-code"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Decode() = %v, want %v", err, ErrInvalidCode)
+	}
+}
+
+func TestPermutation_Bijection(t *testing.T) {
+	// на маленьком размере проверяем все значения, в том числе cycle walking
+	const size = 1000
+	p := newPermutation("0123456789abcdef", size)
+
+	seen := make(map[uint64]bool, size)
+	for n := uint64(0); n < size; n++ {
+		m := p.forward(n)
+		if m >= size || seen[m] {
+			t.Fatalf("forward(%d) = %d, out of range or repeated", n, m)
+		}
+		seen[m] = true
+
+		if back := p.backward(m); back != n {
+			t.Fatalf("backward(forward(%d)) = %d", n, back)
+		}
+	}
+}
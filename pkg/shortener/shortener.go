@@ -3,38 +3,76 @@ package shortener
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"math/big"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
+	// ShortURLLength длина кода по умолчанию
 	ShortURLLength = 10
-	base62Chars    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
-)
 
-// Generate создает код из оригЮРЛ
-func Generate(originalURL string) string {
+	// MaxAliasLength столько символов вмещает колонка short_code
+	MaxAliasLength = 64
 
-	hash := sha256.Sum256([]byte(originalURL))
+	// aliasSafeChars символы, которые можно использовать в пути без экранирования
+	aliasSafeChars = AlphabetDefault + "-.~"
+)
 
-	encoded := base64.RawURLEncoding.EncodeToString(hash[:])
+// AliasPolicy ограничения на пользовательские алиасы
+type AliasPolicy struct {
+	MinLength int
+	MaxLength int
+	Charset   string
+}
 
-	encoded = strings.ReplaceAll(encoded, "-", "_")
+var DefaultAliasPolicy = AliasPolicy{
+	MinLength: 3,
+	MaxLength: 32,
+	Charset:   AlphabetDefault + "-",
+}
 
-	if len(encoded) > ShortURLLength {
-		encoded = encoded[:ShortURLLength]
+var (
+	aliasMu     sync.RWMutex
+	aliasPolicy = DefaultAliasPolicy
+)
+
+// SetAliasPolicy меняет политику алиасов, вызывается при старте
+func SetAliasPolicy(p AliasPolicy) error {
+	if err := p.Check(); err != nil {
+		return err
 	}
 
-	return encoded
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+	aliasPolicy = p
+
+	return nil
 }
 
-// Validate проверка валидности шорткода
-func Validate(shortCode string) bool {
-	if len(shortCode) != ShortURLLength {
+// ValidateAlias проверяет новый алиас по текущей политике. Алиас, похожий
+// на код с неверным контрольным символом, не пройдет, чтобы опечатка в
+// коде не приводила на чужой алиас
+func ValidateAlias(alias string) bool {
+	return currentAliasPolicy().Validate(alias) && !Mistyped(alias)
+}
+
+func currentAliasPolicy() AliasPolicy {
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	return aliasPolicy
+}
+
+// Validate проверяет алиас по политике p
+func (p AliasPolicy) Validate(alias string) bool {
+	if len(alias) < p.MinLength || len(alias) > p.MaxLength {
 		return false
 	}
 
-	for _, ch := range shortCode {
-		if !isValidChar(ch) {
+	for _, ch := range alias {
+		if !strings.ContainsRune(p.Charset, ch) {
 			return false
 		}
 	}
@@ -42,9 +80,84 @@ func Validate(shortCode string) bool {
 	return true
 }
 
-func isValidChar(ch rune) bool {
-	return (ch >= 'a' && ch <= 'z') ||
-		(ch >= 'A' && ch <= 'Z') ||
-		(ch >= '0' && ch <= '9') ||
-		ch == '_'
+// Check проверяет саму политику на корректность
+func (p AliasPolicy) Check() error {
+	if p.MinLength < 1 {
+		return errors.New("alias min length must be positive")
+	}
+	if p.MaxLength > MaxAliasLength {
+		return errors.New("alias max length exceeds storage limit")
+	}
+	if p.MinLength > p.MaxLength {
+		return errors.New("alias min length is greater than max length")
+	}
+	if p.Charset == "" {
+		return errors.New("alias charset is empty")
+	}
+	for _, ch := range p.Charset {
+		if !strings.ContainsRune(aliasSafeChars, ch) {
+			return errors.New("alias charset contains unsafe character: " + string(ch))
+		}
+	}
+
+	return nil
+}
+
+// Generate создает код из оригЮРЛ в текущем формате
+func Generate(originalURL string) string {
+	return encode(sha256.Sum256([]byte(originalURL)), CurrentCodeFormat())
+}
+
+// GenerateAttempt создает код для попытки attempt.
+// Нулевая попытка совпадает с Generate, следующие хешируют URL с солью,
+// чтобы при коллизии получить другой, но все еще детерминированный код
+func GenerateAttempt(originalURL string, attempt int) string {
+	if attempt <= 0 {
+		return Generate(originalURL)
+	}
+
+	salted := originalURL + "\x00" + strconv.Itoa(attempt)
+	return encode(sha256.Sum256([]byte(salted)), CurrentCodeFormat())
+}
+
+// encode переводит хеш в код. Для алфавита по умолчанию это начало base64,
+// как было всегда, для остальных - цифры хеша в системе счисления алфавита
+func encode(hash [sha256.Size]byte, format CodeFormat) string {
+	if format.Alphabet == AlphabetDefault {
+		encoded := base64.RawURLEncoding.EncodeToString(hash[:])
+		encoded = strings.ReplaceAll(encoded, "-", "_")
+		return format.seal(encoded[:format.Length])
+	}
+
+	n := new(big.Int).SetBytes(hash[:])
+	base := big.NewInt(int64(len(format.Alphabet)))
+	digit := new(big.Int)
+
+	code := make([]byte, format.Length)
+	for i := range code {
+		n.DivMod(n, base, digit)
+		code[i] = format.Alphabet[digit.Int64()]
+	}
+	return format.seal(string(code))
+}
+
+// Validate проверка валидности шорткода: сгенерированного в одном из
+// принимаемых форматов или алиаса. Алиасы, созданные до включения
+// контрольного символа, могут выглядеть как код с опечаткой, поэтому
+// здесь Mistyped не проверяется
+func Validate(shortCode string) bool {
+	return validateGenerated(shortCode) || currentAliasPolicy().Validate(shortCode)
+}
+
+func validateGenerated(shortCode string) bool {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	for _, format := range acceptedFormats {
+		if format.Validate(shortCode) {
+			return true
+		}
+	}
+
+	return false
 }
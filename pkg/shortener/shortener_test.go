@@ -91,7 +91,7 @@ func TestGenerateConcurrency(t *testing.T) {
 	}
 }
 
-func TestValidate(t *testing.T) {
+func TestValidateGenerated(t *testing.T) {
 	tests := []struct {
 		name      string
 		shortCode string
@@ -146,9 +146,120 @@ func TestValidate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := Validate(tt.shortCode); got != tt.want {
-				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			if got := validateGenerated(tt.shortCode); got != tt.want {
+				t.Errorf("validateGenerated() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestGenerateAttempt(t *testing.T) {
+	url := "https://example.com/test"
+
+	if got := GenerateAttempt(url, 0); got != Generate(url) {
+		t.Errorf("GenerateAttempt(0) = %s, want %s", got, Generate(url))
+	}
+
+	seen := make(map[string]int)
+	for attempt := 0; attempt < 10; attempt++ {
+		code := GenerateAttempt(url, attempt)
+		if !Validate(code) {
+			t.Errorf("GenerateAttempt(%d) returned invalid short code: %s", attempt, code)
+		}
+		if prev, ok := seen[code]; ok {
+			t.Errorf("GenerateAttempt(%d) = %s, same as attempt %d", attempt, code, prev)
+		}
+		seen[code] = attempt
+
+		if again := GenerateAttempt(url, attempt); again != code {
+			t.Errorf("GenerateAttempt(%d) is not deterministic: %s and %s", attempt, code, again)
+		}
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name  string
+		alias string
+		want  bool
+	}{
+		{
+			name:  "simple alias",
+			alias: "spring-sale",
+			want:  true,
+		},
+		{
+			name:  "min length",
+			alias: "abc",
+			want:  true,
+		},
+		{
+			name:  "too short",
+			alias: "ab",
+			want:  false,
+		},
+		{
+			name:  "too long",
+			alias: "a123456789b123456789c123456789d12",
+			want:  false,
+		},
+		{
+			name:  "slash",
+			alias: "spring/sale",
+			want:  false,
+		},
+		{
+			name:  "space",
+			alias: "spring sale",
+			want:  false,
+		},
+		{
+			name:  "non-ascii",
+			alias: "распродажа",
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateAlias(tt.alias); got != tt.want {
+				t.Errorf("ValidateAlias(%q) = %v, want %v", tt.alias, got, tt.want)
+			}
+			if got := Validate(tt.alias); got != tt.want {
+				t.Errorf("Validate(%q) = %v, want %v", tt.alias, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAliasPolicy(t *testing.T) {
+	defer SetAliasPolicy(DefaultAliasPolicy)
+
+	invalid := []AliasPolicy{
+		{MinLength: 0, MaxLength: 10, Charset: "abc"},
+		{MinLength: 5, MaxLength: 4, Charset: "abc"},
+		{MinLength: 1, MaxLength: MaxAliasLength + 1, Charset: "abc"},
+		{MinLength: 1, MaxLength: 10, Charset: ""},
+		{MinLength: 1, MaxLength: 10, Charset: "abc/"},
+	}
+	for _, p := range invalid {
+		if err := SetAliasPolicy(p); err == nil {
+			t.Errorf("SetAliasPolicy(%+v) expected error, got nil", p)
+		}
+	}
+
+	if err := SetAliasPolicy(AliasPolicy{MinLength: 2, MaxLength: 4, Charset: "ab"}); err != nil {
+		t.Fatalf("SetAliasPolicy() failed: %v", err)
+	}
+	if !ValidateAlias("abba") {
+		t.Error("ValidateAlias(abba) = false, want true")
+	}
+	if ValidateAlias("abc") {
+		t.Error("ValidateAlias(abc) = true, want false")
+	}
+
+	// сгенерированные коды валидны независимо от политики
+	if !Validate(Generate("https://example.com")) {
+		t.Error("Validate() rejected generated code after policy change")
+	}
+}
@@ -0,0 +1,248 @@
+// Package urlnorm приводит URL к каноническому виду, чтобы одинаковые
+// адреса, записанные по-разному, получали один короткий код
+package urlnorm
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var ErrInvalidURL = errors.New("invalid URL")
+
+// Options необязательные шаги нормализации. Регистр схемы и хоста, порт
+// по умолчанию, точки в пути и punycode нормализуются всегда
+type Options struct {
+	// SortQuery сортирует параметры запроса по имени
+	SortQuery bool
+
+	// StripTracking убирает utm_* и другие известные метки рекламы
+	StripTracking bool
+
+	// DropFragment убирает #фрагмент. Сервис включает его по умолчанию,
+	// но SPA хранят во фрагменте маршрут, и для них его стоит выключить
+	DropFragment bool
+}
+
+// trackingParams метки, которые не влияют на содержимое страницы
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+}
+
+// profile как idna.Lookup, но пропускает "_" в хостах: такие имена
+// встречаются на практике, хоть и не проходят STD3
+var profile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.StrictDomainName(false),
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize возвращает канонический вид абсолютного URL
+func Normalize(rawURL string, opts Options) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+
+	host, err := Host(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(host, ":") {
+		// зона IPv6 в URL пишется с экранированным %
+		host = "[" + strings.Replace(host, "%", "%25", 1) + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+	b.WriteString(host)
+
+	path := removeDotSegments(normalizeEscapes(u.EscapedPath()))
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path)
+
+	query := u.RawQuery
+	if opts.SortQuery || opts.StripTracking {
+		query = normalizeQuery(query, opts)
+	}
+	if query != "" {
+		b.WriteByte('?')
+		b.WriteString(normalizeEscapes(query))
+	}
+
+	if fragment := u.EscapedFragment(); fragment != "" && !opts.DropFragment {
+		b.WriteByte('#')
+		b.WriteString(fragment)
+	}
+
+	return b.String(), nil
+}
+
+// Host переводит хост в нижний регистр, а юникодные имена в punycode
+func Host(host string) (string, error) {
+	if host == "" {
+		return "", ErrInvalidURL
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return strings.ToLower(host), nil
+	}
+	// у IPv6 с зоной регистр меняем только в адресе, имя интерфейса как есть
+	if addr, zone, found := strings.Cut(host, "%"); found && zone != "" && strings.Contains(addr, ":") && net.ParseIP(addr) != nil {
+		return strings.ToLower(addr) + "%" + zone, nil
+	}
+
+	ascii, err := profile.ToASCII(host)
+	if err != nil {
+		return "", ErrInvalidURL
+	}
+	return ascii, nil
+}
+
+// normalizeQuery убирает пустые пары и метки и сортирует параметры.
+// Кодирование значений не меняется, сортировка устойчивая, поэтому
+// повторяющиеся параметры сохраняют порядок
+func normalizeQuery(rawQuery string, opts Options) string {
+	type param struct{ key, pair string }
+
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+
+		if opts.StripTracking && isTracking(key) {
+			continue
+		}
+		params = append(params, param{key: key, pair: pair})
+	}
+
+	if opts.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].key < params[j].key
+		})
+	}
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+func isTracking(key string) bool {
+	key = strings.ToLower(key)
+	return strings.HasPrefix(key, "utm_") || trackingParams[key]
+}
+
+// removeDotSegments убирает . и .. из пути по RFC 3986 5.2.4
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			// корневой пустой сегмент не удаляем
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+
+	return strings.Join(out, "/")
+}
+
+// normalizeEscapes пишет %-последовательности в верхнем регистре и
+// раскодирует незарезервированные символы, например %7E в ~
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}